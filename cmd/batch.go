@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/dannyhinshaw/converge/cmd/converge"
+)
+
+// loadBatch reads the mapping of source directories
+// to destination files from the given YAML file.
+func loadBatch(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch file %s: %w", path, err)
+	}
+
+	var dirs map[string]string
+	if err = yaml.Unmarshal(b, &dirs); err != nil {
+		return nil, fmt.Errorf("failed to parse batch file %s: %w", path, err)
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("batch file %s has no directories to converge", path)
+	}
+
+	return dirs, nil
+}
+
+// runBatch converges every source directory in the
+// batch file into its own destination file.
+func (c *cmd) runBatch(ctx context.Context) error {
+	dirs, err := loadBatch(c.batch)
+	if err != nil {
+		return err
+	}
+
+	// Create one converger upfront to catch invalid
+	// options before any directory is converged.
+	if _, err = createConverger(ctx, nil, c); err != nil {
+		return fmt.Errorf("failed to create converger: %w", err)
+	}
+	newFC := func() (converge.FileConverger, error) {
+		return createConverger(ctx, c.lg.WithName("converger"), c)
+	}
+
+	m := converge.NewMultiDirConverger(newFC, dirs,
+		converge.WithCommandOptions(commandOptions(c)...),
+	)
+	if err = m.Run(ctx); err != nil {
+		return fmt.Errorf("failed to run batch: %w", err)
+	}
+
+	for dir, dst := range dirs {
+		c.lg.Infof("Successfully merged '%s' into '%s'.", dir, dst)
+	}
+
+	return nil
+}
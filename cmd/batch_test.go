@@ -0,0 +1,70 @@
+package cmd_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot_Batch(t *testing.T) {
+	r := require.New(t)
+
+	outDir := t.TempDir()
+	var batch strings.Builder
+	for i := range 3 {
+		dir := createTempDirWithFiles(t, map[string]string{
+			"file.go": fmt.Sprintf("package pkg%d\nfunc func%d() {}", i, i),
+		})
+		fmt.Fprintf(&batch, "%s: %s\n", dir, filepath.Join(outDir, fmt.Sprintf("pkg%d.go", i)))
+	}
+
+	batchFile := filepath.Join(t.TempDir(), "batch.yaml")
+	r.NoError(os.WriteFile(batchFile, []byte(batch.String()), 0o644))
+
+	stdout, stderr := executeRoot(t, "--batch", batchFile)
+	r.Empty(stdout)
+	r.Empty(stderr)
+
+	for i := range 3 {
+		content, err := os.ReadFile(filepath.Join(outDir, fmt.Sprintf("pkg%d.go", i)))
+		r.NoError(err)
+		r.Equal(fmt.Sprintf("package pkg%d\n\nfunc func%d() {}\n", i, i), string(content))
+	}
+}
+
+func TestRoot_BatchErrors(t *testing.T) {
+	tests := map[string]struct {
+		batch  string
+		errMsg string
+	}{
+		"Empty": {
+			batch:  "",
+			errMsg: "has no directories to converge",
+		},
+		"InvalidYAML": {
+			batch:  "- not a mapping\n",
+			errMsg: "failed to parse batch file",
+		},
+		"MissingSourceDirectory": {
+			batch:  "/invalid/dir: out.go\n",
+			errMsg: "/invalid/dir does not exist",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			batchFile := filepath.Join(t.TempDir(), "batch.yaml")
+			r.NoError(os.WriteFile(batchFile, []byte(tc.batch), 0o644))
+
+			stdout, _, err := execute("--batch", batchFile)
+			r.Empty(stdout)
+			r.ErrorContains(err, tc.errMsg)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dannyhinshaw/converge/cmd/converge"
+)
+
+// hasChecks returns true if any checks
+// of the converged output are enabled.
+func (c *cmd) hasChecks() bool {
+	return c.vet || c.verifyCompile
+}
+
+// runChecks converges the files and runs the enabled checks on the
+// output. When the output is written to stdout, it is checked first
+// and only written to stdout if all the checks pass.
+func (c *cmd) runChecks(ctx context.Context, converger converge.FileConverger) error {
+	if c.outfile != "" {
+		if err := createCommand(converger, c).Run(ctx); err != nil {
+			return fmt.Errorf("failed to run command: %w", err)
+		}
+
+		src, err := os.ReadFile(c.outfile)
+		if err != nil {
+			return fmt.Errorf("failed to read output file %s: %w", c.outfile, err)
+		}
+		return c.check(ctx, c.outfile, src)
+	}
+
+	var buf bytes.Buffer
+	cmdOpts := append(commandOptions(c), converge.WithWriter(&buf))
+	if err := converge.NewCommand(converger, c.dir, cmdOpts...).Run(ctx); err != nil {
+		return fmt.Errorf("failed to run command: %w", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "converge-check")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for checks: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	tmpFile := filepath.Join(tmpDir, "converged.go")
+	if err = os.WriteFile(tmpFile, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("failed to write temp file for checks: %w", err)
+	}
+	if err = c.check(ctx, tmpFile, buf.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err = c.stdout.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// check runs the enabled checks on the converged
+// output, which has been written to the given path.
+func (c *cmd) check(ctx context.Context, path string, src []byte) error {
+	if c.vet {
+		if err := vetFile(ctx, path); err != nil {
+			return err
+		}
+	}
+	if c.verifyCompile {
+		if err := verifyCompile(ctx, src); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
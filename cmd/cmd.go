@@ -5,9 +5,17 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"go/build/constraint"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -29,6 +37,14 @@ const dopeASCII = `
 // cancelling the converge operation.
 const defaultTimeout = 15 * time.Second
 
+const (
+	// logFormatText is the log format for human-readable log messages.
+	logFormatText = "text"
+
+	// logFormatJSON is the log format for JSON log messages.
+	logFormatJSON = "json"
+)
+
 // usageTemplate is a utility function for replacing the default usage
 // template with any custom usage template in a central location.
 func usageTemplate(c cobra.Command) string {
@@ -47,42 +63,54 @@ func NewRoot(version string) *cobra.Command {
 		Long: `
 Converge merges multiple Go source files from a directory into a single file.
 
-By default, the tool does not process directories recursively. You can specify 
-the source directory with the --dir flag and an output file using --output. If 
-no output file is provided, the result will be printed to stdout. You can exclude
-files by providing regular expressions with the --exclude flag.
+By default, the tool does not process directories recursively; use --recursive
+to include subdirectories. You can specify the source directory with the --dir
+flag and an output file using --output. If no output file is provided, the result
+will be printed to stdout. You can exclude files by providing regular expressions
+with the --exclude flag.
 
 The result is formatted according to Go's standard "gofmt" style.
-`,
-		Args: cobra.MaximumNArgs(0),
-		Run: func(cmd *cobra.Command, _ []string) {
-			ctx, cancel := context.WithTimeout(cmd.Context(), rootCmd.timeout)
-			defer cancel()
 
-			// Default to only logging errors.
-			lvl := olog.LevelError
-			if rootCmd.verbose {
-				lvl = olog.LevelDebug
-			}
+Defaults for the --dir, --output, --timeout, --verbose and --workers flags can
+be set with the CONVERGE_DIR, CONVERGE_OUTPUT, CONVERGE_TIMEOUT, CONVERGE_VERBOSE
+and CONVERGE_WORKERS environment variables. Flags take precedence over them.
 
-			lg := olog.NewLogger(lvl).
-				WithName("converge")
+Defaults for any flag can also be set in a YAML config file, keyed by the long
+flag names. The config file is read from .converge.yaml in the current directory,
+or from the path given with --config. Flags and environment variables take
+precedence over the config file.
+`,
+		Args:          cobra.MaximumNArgs(0),
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			// Flags take precedence over the environment,
+			// which takes precedence over the config file.
+			if err := applyEnv(cmd); err != nil {
+				return err
+			}
+			if err := applyConfig(cmd, rootCmd.configFile); err != nil {
+				return err
+			}
+			return rootCmd.validateFlags()
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel, lg := rootCmd.setup(cmd, "rootCmd")
+			defer cancel()
 
 			lg.Info("Starting converge operation...")
-			lg.Debug("Verbose logging enabled.")
-
-			rootCmd.lg = lg.WithName("rootCmd")
 			if err := rootCmd.run(ctx); err != nil {
-				lg.Error("failed to run command:", err)
-				return
+				return fmt.Errorf("failed to run command: %w", err)
 			}
 
 			// Only print success message if an outfile was provided.
 			// This is to prevent the success message from being printed
 			// when the converged code output is written to stdout.
-			if rootCmd.outfile != "" {
+			if rootCmd.outfile != "" && !rootCmd.dryRun {
 				lg.Info("Converge operation completed successfully.")
 			}
+
+			return nil
 		},
 	}
 
@@ -91,14 +119,21 @@ The result is formatted according to Go's standard "gofmt" style.
 		usageTemplate(c),
 	)
 
+	c.AddCommand(
+		newValidateCmd(&rootCmd),
+		newListCmd(&rootCmd),
+	)
+
 	return &c
 }
 
 // bindFlags handles binding the command-line flags to the root command.
+// Flags shared with subcommands are bound as persistent flags.
 func bindFlags(c *cobra.Command, rootCmd *cmd) {
 	fs := c.Flags()
+	pfs := c.PersistentFlags()
 
-	fs.StringVarP(&rootCmd.dir,
+	pfs.StringVarP(&rootCmd.dir,
 		"dir", "d", ".",
 		"The directory containing Go files to merge",
 	)
@@ -106,22 +141,216 @@ func bindFlags(c *cobra.Command, rootCmd *cmd) {
 		"output", "o", "",
 		"File to write the merged Go code (default: stdout)",
 	)
-	fs.StringSliceVarP(&rootCmd.exclude,
+	pfs.StringSliceVarP(&rootCmd.exclude,
 		"exclude", "e", nil,
 		"Regular expressions for filenames to exclude from merging",
 	)
-	fs.DurationVarP(&rootCmd.timeout,
+	pfs.StringSliceVarP(&rootCmd.excludeDirs,
+		"exclude-dirs", "D", nil,
+		"Regular expressions for subdirectory names to skip entirely with --recursive",
+	)
+	pfs.BoolVarP(&rootCmd.recursive,
+		"recursive", "r", false,
+		"Merge Go files in all subdirectories of the directory as well",
+	)
+	pfs.IntVar(&rootCmd.maxDepth,
+		"max-depth", -1,
+		"Maximum depth of subdirectories to merge with --recursive (default: unlimited)",
+	)
+	pfs.BoolVar(&rootCmd.followSymlinks,
+		"follow-symlinks", false,
+		"Follow symbolic links to directories with --recursive",
+	)
+	pfs.Int64Var(&rootCmd.minFileSize,
+		"min-file-size", 0,
+		"Minimum size in bytes of files to merge",
+	)
+	pfs.Int64Var(&rootCmd.maxFileSize,
+		"max-file-size", 0,
+		"Maximum size in bytes of files to merge (default: no maximum)",
+	)
+	pfs.IntVar(&rootCmd.maxFiles,
+		"max-files", 0,
+		"Fail if there are more than this many files to merge, e.g. when walking the wrong directory (default: no limit)",
+	)
+	pfs.DurationVar(&rootCmd.timeoutPerFile,
+		"timeout-per-file", 0,
+		"Skip files that take longer than this to process, with a warning (e.g., '10s') (default: no limit)",
+	)
+	pfs.BoolVar(&rootCmd.dedup,
+		"dedup", false,
+		"Remove top-level declarations that duplicate earlier ones, keeping the first",
+	)
+	pfs.StringSliceVarP(&rootCmd.packages,
+		"packages", "p", nil,
+		"Package names to include in the merge (default: all packages)",
+	)
+	pfs.BoolVarP(&rootCmd.includeTests,
+		"include-tests", "T", false,
+		"Include '_test.go' files in the merge (always included with --packages)",
+	)
+	pfs.IntVarP(&rootCmd.workers,
+		"workers", "n", 0,
+		"Number of workers used to process files (default: number of CPUs, up to 32)",
+	)
+	fs.BoolVarP(&rootCmd.appendMode,
+		"append", "a", false,
+		"Append the merged Go code to the output file instead of overwriting it",
+	)
+	fs.BoolVar(&rootCmd.backup,
+		"backup", false,
+		"Copy an existing output file to '<output>.bak' before overwriting it",
+	)
+	fs.BoolVar(&rootCmd.backupTimestamped,
+		"backup-timestamped", false,
+		"Copy an existing output file to a timestamped '.bak' file before overwriting it",
+	)
+	fs.StringVar(&rootCmd.batch,
+		"batch", "",
+		"YAML file mapping source directories to output files, to merge each into its own file",
+	)
+	fs.BoolVar(&rootCmd.vet,
+		"vet", false,
+		"Run 'go vet' on the merged output and fail if it reports any issues (within --timeout)",
+	)
+	fs.BoolVar(&rootCmd.verifyCompile,
+		"verify-compile", false,
+		"Compile the merged output on its own and fail if it doesn't compile (within --timeout)",
+	)
+	fs.BoolVarP(&rootCmd.list,
+		"list", "l", false,
+		"List the files that would be merged and exit without merging",
+	)
+	fs.BoolVar(&rootCmd.dryRun,
+		"dry-run", false,
+		"Print the merged output to stdout instead of writing the output file",
+	)
+	fs.BoolVar(&rootCmd.checksum,
+		"checksum", false,
+		"Print the SHA-256 checksum of the merged output (on stderr if the output goes to stdout)",
+	)
+	fs.BoolVar(&rootCmd.printImports,
+		"print-imports", false,
+		"Print the unique import paths of the files that would be merged and exit without merging",
+	)
+	fs.BoolVar(&rootCmd.jsonReport,
+		"json", false,
+		"Print a JSON report of the package, files, imports and declarations merged instead of the output",
+	)
+	fs.BoolVar(&rootCmd.jsonIncludeSource,
+		"json-include-source", false,
+		"Include the merged output, base64-encoded, in the JSON report of --json",
+	)
+	fs.StringSliceVar(&rootCmd.fileOrder,
+		"file-order", nil,
+		"Base names of files to merge first, in the given order (e.g. 'doc.go')",
+	)
+	fs.StringSliceVar(&rootCmd.importOrder,
+		"import-order", nil,
+		"Import path prefixes of the import groups, in order (e.g. 'github.com/myorg/'); other imports go last",
+	)
+	fs.StringVar(&rootCmd.modulePath,
+		"module", "",
+		"Path of the Go module of the files (e.g. 'github.com/myorg/mylib'), to warn about imports of its own packages",
+	)
+	fs.BoolVar(&rootCmd.fileAttribution,
+		"file-attribution", false,
+		"Precede the code of each merged file with a '// Source: <path>' comment",
+	)
+	fs.StringVar(&rootCmd.separator,
+		"separator", "",
+		"Comment to precede the code of each merged file with, where '%s' is the file path (e.g. '// --- %s ---')",
+	)
+	fs.BoolVar(&rootCmd.sortDecls,
+		"sort-declarations", false,
+		"Sort top-level declarations alphabetically by name",
+	)
+	fs.BoolVar(&rootCmd.stripComments,
+		"strip-comments", false,
+		"Remove all comments from the merged output",
+	)
+	fs.BoolVar(&rootCmd.stripDocComments,
+		"strip-doc-comments", false,
+		"Remove the doc comments of exported declarations from the merged output",
+	)
+	fs.StringVar(&rootCmd.tag,
+		"tag", "",
+		"Build constraint expression to add to the merged output, e.g. 'linux && amd64'",
+	)
+	fs.StringVar(&rootCmd.formatter,
+		"formatter", "",
+		"External command to format the merged output with, e.g. 'gofumpt' (default: go/format)",
+	)
+	fs.StringVar(&rootCmd.prefix,
+		"prefix", "",
+		"Prefix to add to the names of all exported top-level declarations, e.g. 'Vendored'",
+	)
+	fs.StringVar(&rootCmd.suffix,
+		"suffix", "",
+		"Suffix to add to the names of all exported top-level declarations, e.g. 'V2'",
+	)
+	fs.BoolVar(&rootCmd.noFormat,
+		"no-format", false,
+		"Skip formatting the merged output with go/format, streaming it to the output where possible",
+	)
+	fs.StringVar(&rootCmd.outputMode,
+		"output-mode", string(gonverge.OutputModeFull),
+		"Parts of the merged file to output: 'full', 'snippet' (no package clause) or 'declarations' (no imports either)",
+	)
+	pfs.DurationVarP(&rootCmd.timeout,
 		"timeout", "t", defaultTimeout,
-		"Maximum duration before canceling the operation (e.g., '5s', '1m')",
+		"Maximum duration before canceling the operation, including --vet and --verify-compile (e.g., '5s', '1m')",
 	)
-	fs.BoolVarP(&rootCmd.verbose,
+	pfs.BoolVarP(&rootCmd.verbose,
 		"verbose", "v", false,
 		"Enable verbose logging for debugging purposes",
 	)
+	pfs.BoolVarP(&rootCmd.quiet,
+		"quiet", "q", false,
+		"Only log errors, even if --verbose is set",
+	)
+	pfs.StringVar(&rootCmd.configFile,
+		"config", "",
+		"Path to a YAML config file (default: "+defaultConfigFile+" if it exists)",
+	)
+	pfs.StringVar(&rootCmd.logFormat,
+		"log-format", logFormatText,
+		"Format of log messages, either 'text' or 'json'",
+	)
+	pfs.StringVar(&rootCmd.logFile,
+		"log-file", "",
+		"Append log messages to the given file instead of stderr",
+	)
+	pfs.BoolVar(&rootCmd.logFileInfo,
+		"log-file-info", false,
+		"Include the source file and line of the caller in log messages (always on with --verbose)",
+	)
+
+	// Complete the paths of the directory and
+	// output file flags in shell completions.
+	_ = c.MarkPersistentFlagDirname("dir")
+	_ = c.MarkFlagFilename("output", "go")
+	_ = c.MarkFlagFilename("batch", "yaml", "yml")
+	_ = c.MarkPersistentFlagFilename("log-file")
+	c.MarkFlagsMutuallyExclusive("batch", "output")
+	c.MarkFlagsMutuallyExclusive("batch", "list")
+	c.MarkFlagsMutuallyExclusive("batch", "print-imports")
+	c.MarkFlagsMutuallyExclusive("batch", "dry-run")
+	c.MarkFlagsMutuallyExclusive("list", "print-imports")
+	c.MarkFlagsMutuallyExclusive("checksum", "batch")
+	c.MarkFlagsMutuallyExclusive("checksum", "list")
+	c.MarkFlagsMutuallyExclusive("checksum", "print-imports")
+	c.MarkFlagsMutuallyExclusive("checksum", "dry-run")
+	c.MarkFlagsMutuallyExclusive("json", "batch")
+	c.MarkFlagsMutuallyExclusive("json", "output")
+	c.MarkFlagsMutuallyExclusive("json", "list")
+	c.MarkFlagsMutuallyExclusive("json", "print-imports")
+	c.MarkFlagsMutuallyExclusive("json", "dry-run")
+	c.MarkFlagsMutuallyExclusive("json", "checksum")
+	_ = c.MarkPersistentFlagFilename("config", "yaml", "yml")
+
 	// Note(@danny): In the future add a flag that allows users
 	// to configure words to replace in the converged file.
-	// Also, add ability to remove duplicate imports, types,
-	// functions (etc), from the converged file.
 }
 
 // cmd holds the command-line options and utilities
@@ -130,6 +359,14 @@ type cmd struct {
 	// lg is the logger for the command.
 	lg olog.LevelLogger
 
+	// stdout is where the converged output is written
+	// when no output file is specified.
+	stdout io.Writer
+
+	// stderr is where the checksum of the converged
+	// output is written if that went to stdout.
+	stderr io.Writer
+
 	// dir is the source directory containing
 	// Go source files to be converged.
 	dir string
@@ -143,6 +380,157 @@ type cmd struct {
 	// excluding files from converge if they match.
 	exclude []string
 
+	// excludeDirs is a list of regex patterns for the names
+	// of subdirectories to skip when walking recursively.
+	excludeDirs []string
+
+	// recursive converges the files in all
+	// subdirectories of dir as well.
+	recursive bool
+
+	// maxDepth limits how many levels of subdirectories
+	// are converged when recursive; -1 is unlimited.
+	maxDepth int
+
+	// followSymlinks follows symbolic links to
+	// directories when converging recursively.
+	followSymlinks bool
+
+	// minFileSize is the minimum size in
+	// bytes of files to converge.
+	minFileSize int64
+
+	// maxFileSize is the maximum size in bytes of
+	// files to converge; 0 means no maximum.
+	maxFileSize int64
+
+	// maxFiles is the maximum amount of files
+	// to converge; 0 means no maximum.
+	maxFiles int
+
+	// timeoutPerFile is the maximum time to process a single
+	// file before it is skipped; 0 means no maximum.
+	timeoutPerFile time.Duration
+
+	// packages is a list of package names used to filter
+	// which files are converged; empty includes all.
+	packages []string
+
+	// includeTests includes test files in the
+	// merge, which are excluded by default.
+	includeTests bool
+
+	// workers is the number of workers to use for
+	// processing files; 0 uses the number of CPUs.
+	workers int
+
+	// appendMode appends the converged output to
+	// the output file instead of overwriting it.
+	appendMode bool
+
+	// backup copies an existing output file
+	// to a backup file before overwriting it.
+	backup bool
+
+	// backupTimestamped is like backup, but adds a
+	// timestamp to the name of the backup file.
+	backupTimestamped bool
+
+	// batch is the path to a YAML file mapping source
+	// directories to the files to converge them into.
+	batch string
+
+	// vet runs go vet on the converged output.
+	vet bool
+
+	// verifyCompile compiles the converged output
+	// on its own to check that it compiles.
+	verifyCompile bool
+
+	// list prints the files that would be converged
+	// instead of running the converge operation.
+	list bool
+
+	// dryRun prints the converged output to stdout
+	// instead of writing it to the output file.
+	dryRun bool
+
+	// checksum prints the SHA-256 checksum of
+	// the converged output after writing it.
+	checksum bool
+
+	// printImports prints the import paths of the files that
+	// would be converged instead of running the converge operation.
+	printImports bool
+
+	// jsonReport prints a JSON report describing
+	// the converged output instead of the output.
+	jsonReport bool
+
+	// jsonIncludeSource includes the converged
+	// output in the JSON report.
+	jsonIncludeSource bool
+
+	// fileOrder is the base names of the
+	// files to converge first, in order.
+	fileOrder []string
+
+	// importOrder are the import path prefixes
+	// of the import groups of the output, in order.
+	importOrder []string
+
+	// modulePath is the path of the Go
+	// module the files belong to.
+	modulePath string
+
+	// fileAttribution precedes the code of each
+	// merged file with a comment naming its source.
+	fileAttribution bool
+
+	// separator is the comment template to precede
+	// the code of each merged file with, if any.
+	separator string
+
+	// dedup removes duplicate top-level
+	// declarations from the output.
+	dedup bool
+
+	// sortDecls sorts top-level declarations
+	// of the output alphabetically by name.
+	sortDecls bool
+
+	// stripComments removes all comments
+	// from the converged output.
+	stripComments bool
+
+	// stripDocComments removes the doc comments of
+	// exported declarations from the converged output.
+	stripDocComments bool
+
+	// tag is the build constraint expression
+	// to add to the converged output.
+	tag string
+
+	// formatter is an external command used to format
+	// the converged output instead of go/format.
+	formatter string
+
+	// prefix is added to the names of all exported
+	// top-level declarations of the output.
+	prefix string
+
+	// suffix is added to the names of all exported
+	// top-level declarations of the output.
+	suffix string
+
+	// noFormat skips formatting the converged
+	// output with go/format.
+	noFormat bool
+
+	// outputMode determines which parts of
+	// the converged file are written.
+	outputMode string
+
 	// timeout is the maximum time (in seconds) before
 	// cancelling the converge operation.
 	timeout time.Duration
@@ -150,24 +538,195 @@ type cmd struct {
 	// verbose enables verbose logging
 	// for debugging purposes.
 	verbose bool
+
+	// quiet suppresses all log messages except
+	// errors, taking precedence over verbose.
+	quiet bool
+
+	// configFile is the path to the YAML config
+	// file to read flag defaults from.
+	configFile string
+
+	// logFormat is the format of log messages,
+	// either logFormatText or logFormatJSON.
+	logFormat string
+
+	// logFile is the path to the file to append log
+	// messages to, if they shouldn't go to stderr.
+	logFile string
+
+	// logFileInfo includes the source file and line of
+	// the caller in log messages at every log level.
+	logFileInfo bool
+}
+
+// validateFlags checks that the command-line flags
+// have valid values before the command is run.
+func (c *cmd) validateFlags() error {
+	switch c.logFormat {
+	case logFormatText, logFormatJSON:
+	default:
+		return fmt.Errorf("invalid log format %q: must be %q or %q",
+			c.logFormat, logFormatText, logFormatJSON)
+	}
+
+	if c.timeoutPerFile < 0 {
+		return fmt.Errorf("invalid timeout per file %s: must not be negative", c.timeoutPerFile)
+	}
+
+	if c.jsonIncludeSource && !c.jsonReport {
+		return errors.New("--json-include-source can only be used with --json")
+	}
+
+	// Check the build tag before the output file is
+	// opened, since opening it truncates any existing file.
+	if c.tag != "" {
+		if _, err := constraint.Parse("//go:build " + c.tag); err != nil {
+			return fmt.Errorf("invalid build tag %q: %w", c.tag, err)
+		}
+	}
+
+	switch mode := gonverge.OutputMode(c.outputMode); mode {
+	case gonverge.OutputModeFull:
+	case gonverge.OutputModeSnippet, gonverge.OutputModeDeclarations:
+		// Without a package clause the output
+		// can't be vetted or compiled on its own.
+		if c.hasChecks() {
+			return fmt.Errorf("output mode %q can't be used with --vet or --verify-compile", mode)
+		}
+	default:
+		return fmt.Errorf("invalid output mode %q: must be %q, %q or %q", mode,
+			gonverge.OutputModeFull, gonverge.OutputModeSnippet, gonverge.OutputModeDeclarations)
+	}
+
+	// Renamed declarations must stay exported,
+	// and their names must still be valid.
+	if c.prefix != "" && !token.IsExported(c.prefix) {
+		return fmt.Errorf("invalid prefix %q: must start with an upper case letter to keep names exported", c.prefix)
+	}
+	if !token.IsIdentifier(c.prefix + "X" + c.suffix) {
+		return fmt.Errorf("invalid prefix %q or suffix %q: renamed names must be valid identifiers",
+			c.prefix, c.suffix)
+	}
+
+	if strings.ContainsAny(c.separator, "\r\n") {
+		return fmt.Errorf("invalid separator %q: must be a single line", c.separator)
+	}
+
+	return nil
+}
+
+// setup prepares the command for running by creating its logger
+// and a context that is cancelled once the timeout has passed.
+// The returned logger is the top-level logger for the CLI, while
+// the command's own logger is named after the given name. If a log
+// file is used, it is closed by the returned cancel function.
+func (c *cmd) setup(cc *cobra.Command, name string) (context.Context, context.CancelFunc, olog.LevelLogger) {
+	ctx, cancel := context.WithTimeout(cc.Context(), c.timeout)
+
+	w := cc.ErrOrStderr()
+	if c.logFile != "" {
+		switch f, err := os.OpenFile(c.logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); {
+		case err == nil:
+			w = f
+			cancelCtx := cancel
+			cancel = func() {
+				cancelCtx()
+				_ = f.Close()
+			}
+		case !c.quiet:
+			// Logs are not worth failing the command over,
+			// so warn and carry on logging to stderr.
+			_, _ = fmt.Fprintf(w, "warning: failed to open log file %s, logging to stderr: %v\n", c.logFile, err)
+		}
+	}
+
+	lg := c.newLogger(w).
+		WithName("converge")
+	lg.Debug("Verbose logging enabled.")
+
+	c.lg = lg.WithName(name)
+	c.stdout = cc.OutOrStdout()
+	c.stderr = cc.ErrOrStderr()
+
+	return ctx, cancel, lg
+}
+
+// newLogger creates the logger for the
+// command based on the logging flags.
+func (c *cmd) newLogger(w io.Writer) olog.LevelLogger {
+	// Default to only logging errors.
+	lvl := olog.LevelError
+	switch {
+	case c.quiet:
+		lvl = olog.LevelError
+	case c.verbose:
+		lvl = olog.LevelDebug
+	}
+
+	opts := []olog.Option{
+		olog.WithWriter(w),
+		olog.WithFileInfo(c.logFileInfo),
+	}
+	if c.logFormat == logFormatJSON {
+		opts = append(opts, olog.WithJSON(true))
+	}
+
+	return olog.NewLogger(lvl, opts...)
 }
 
 // run executes the converge command.
 func (c *cmd) run(ctx context.Context) error {
 	c.lg.Debug("Starting converge command")
 
+	if c.batch != "" {
+		return c.runBatch(ctx)
+	}
+
 	// Create the converger that will handle
 	// the low level processing of the files.
-	converger, err := createConverger(c.lg.WithName("converger"), c.exclude)
+	converger, err := createConverger(ctx, c.lg.WithName("converger"), c)
 	if err != nil {
 		return fmt.Errorf("failed to create converger: %w", err)
 	}
 
-	// Create the command that will run the converger
-	// and write the output to the specified file.
-	convergeCmd := createCommand(converger, c.dir, c.outfile)
-	if err = convergeCmd.Run(ctx); err != nil {
-		return fmt.Errorf("failed to run command: %w", err)
+	if c.list {
+		return c.listFiles(ctx, converger, listFormatPlain)
+	}
+	if c.printImports {
+		return c.listImports(ctx, converger)
+	}
+	if c.jsonReport {
+		return c.printReport(ctx, converger)
+	}
+	if c.dryRun {
+		return c.preview(ctx, converger)
+	}
+
+	// Keep a copy of output written to stdout, so
+	// its checksum can be printed once it's written.
+	var written bytes.Buffer
+	if c.checksum && c.outfile == "" {
+		c.stdout = io.MultiWriter(c.stdout, &written)
+	}
+
+	if c.hasChecks() {
+		if err = c.runChecks(ctx, converger); err != nil {
+			return err
+		}
+	} else {
+		// Create the command that will run the converger
+		// and write the output to the specified file.
+		convergeCmd := createCommand(converger, c)
+		if err = convergeCmd.Run(ctx); err != nil {
+			return fmt.Errorf("failed to run command: %w", err)
+		}
+	}
+
+	if c.checksum {
+		if err = c.printChecksum(written.Bytes()); err != nil {
+			return err
+		}
 	}
 
 	c.lg.Debug("Converge command completed successfully.")
@@ -179,36 +738,201 @@ func (c *cmd) run(ctx context.Context) error {
 	return nil
 }
 
+// preview writes the converged output to stdout
+// without writing to the output file, if any.
+func (c *cmd) preview(ctx context.Context, converger *gonverge.GoFileConverger) error {
+	out, err := converge.NewCommand(converger, c.dir).Preview(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to preview command: %w", err)
+	}
+	if _, err = c.stdout.Write(out); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
 // createCommand creates a new converge.Command with the given options.
-func createCommand(converger converge.FileConverger, dir, outFile string) *converge.Command {
+func createCommand(converger converge.FileConverger, c *cmd) *converge.Command {
+	cmdOpts := commandOptions(c)
+	if c.stdout != nil {
+		cmdOpts = append(cmdOpts, converge.WithWriter(c.stdout))
+	}
+	if c.outfile != "" {
+		cmdOpts = append(cmdOpts, converge.WithDstFile(c.outfile))
+	}
+	return converge.NewCommand(converger, c.dir, cmdOpts...)
+}
+
+// printChecksum prints the checksum of the converged output. The
+// checksum of an output file is printed to stdout, but if the output
+// itself was written to stdout, its checksum is printed to stderr
+// instead so the output stays valid Go.
+func (c *cmd) printChecksum(written []byte) error {
+	if c.outfile == "" {
+		if _, err := fmt.Fprintln(c.stderr, gonverge.Checksum(written)); err != nil {
+			return fmt.Errorf("failed to write checksum: %w", err)
+		}
+		return nil
+	}
+
+	src, err := os.ReadFile(c.outfile)
+	if err != nil {
+		return fmt.Errorf("failed to read output file %s: %w", c.outfile, err)
+	}
+	if _, err = fmt.Fprintln(c.stdout, gonverge.Checksum(src)); err != nil {
+		return fmt.Errorf("failed to write checksum: %w", err)
+	}
+
+	return nil
+}
+
+// commandOptions returns the converge.Command options for how the
+// output is written, independent of where it is written to.
+func commandOptions(c *cmd) []converge.Option {
 	var cmdOpts []converge.Option
-	if outFile != "" {
-		cmdOpts = append(cmdOpts, converge.WithDstFile(outFile))
+	if c.appendMode {
+		cmdOpts = append(cmdOpts, converge.WithAppendMode(true))
+	}
+	if c.backup {
+		cmdOpts = append(cmdOpts, converge.WithBackup(true))
 	}
-	return converge.NewCommand(converger, dir, cmdOpts...)
+	if c.backupTimestamped {
+		cmdOpts = append(cmdOpts, converge.WithBackupTimestamped(true))
+	}
+	return cmdOpts
 }
 
 // createConverger creates a new gonverge.GoFileConverger by handling
-// which options to set and passed into the converger.
-func createConverger(lg olog.LevelLogger, ex []string) (*gonverge.GoFileConverger, error) {
+// which options to set and passed into the converger. The given context
+// bounds any external commands the converger runs, e.g. the formatter.
+func createConverger(ctx context.Context, lg olog.LevelLogger, c *cmd) (*gonverge.GoFileConverger, error) {
 	var gonvOpts []gonverge.Option
 	if lg != nil {
 		gonvOpts = append(gonvOpts, gonverge.WithLogger(
 			lg.WithName("gonverge"),
 		))
 	}
+	// Zero keeps the converger's default, which is
+	// the number of CPUs, up to 32 workers.
+	switch {
+	case c.workers < 0:
+		return nil, fmt.Errorf("invalid number of workers %d: must be at least 1", c.workers)
+	case c.workers > 0:
+		gonvOpts = append(gonvOpts, gonverge.WithMaxWorkers(c.workers))
+	}
+	if c.recursive {
+		gonvOpts = append(gonvOpts, gonverge.WithRecursive(true), gonverge.WithMaxDepth(c.maxDepth))
+	}
+	if c.followSymlinks {
+		gonvOpts = append(gonvOpts, gonverge.WithFollowSymlinks(true))
+	}
+	if c.minFileSize > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithMinFileSize(c.minFileSize))
+	}
+	if c.maxFileSize > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithMaxFileSize(c.maxFileSize))
+	}
+	if c.maxFiles > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithMaxFiles(c.maxFiles))
+	}
+	if c.timeoutPerFile > 0 {
+		gonvOpts = append(gonvOpts,
+			gonverge.WithWorkerTimeout(c.timeoutPerFile),
+			gonverge.WithSkipTimedOutFiles(true),
+		)
+	}
+	if len(c.packages) > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithPackages(c.packages))
+	}
+	if c.includeTests {
+		gonvOpts = append(gonvOpts, gonverge.WithExcludeTestFiles(false))
+	}
+	if len(c.fileOrder) > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithFileOrder(c.fileOrder))
+	}
+	if len(c.importOrder) > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithImportOrder(c.importOrder))
+	}
+	if c.modulePath != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithModulePath(c.modulePath))
+	}
+	if c.fileAttribution {
+		gonvOpts = append(gonvOpts, gonverge.WithFileAttribution(true))
+	}
+	if c.separator != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithSeparator(c.separator))
+	}
+	if c.dedup {
+		gonvOpts = append(gonvOpts, gonverge.WithDeduplication(true))
+	}
+	if c.sortDecls {
+		gonvOpts = append(gonvOpts, gonverge.WithSortDeclarations(true))
+	}
+	if c.stripComments {
+		gonvOpts = append(gonvOpts, gonverge.WithStripComments(true))
+	}
+	if c.stripDocComments {
+		gonvOpts = append(gonvOpts, gonverge.WithStripDocComments(true))
+	}
+	if c.noFormat {
+		// Unformatted output is streamed when no other
+		// option needs the full source in memory.
+		gonvOpts = append(gonvOpts, gonverge.WithNoFormat(true), gonverge.WithStreaming(true))
+	}
+	if c.tag != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithBuildTag(c.tag))
+	}
+	if c.prefix != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithExportedPrefix(c.prefix))
+	}
+	if c.suffix != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithExportedSuffix(c.suffix))
+	}
+	if c.outputMode != "" {
+		gonvOpts = append(gonvOpts, gonverge.WithOutputMode(gonverge.OutputMode(c.outputMode)))
+	}
+	if c.formatter != "" {
+		formatter, err := externalFormatter(ctx, c.formatter)
+		if err != nil {
+			return nil, err
+		}
+		gonvOpts = append(gonvOpts, gonverge.WithCustomFormatter(formatter))
+	}
+	if c.verbose && lg != nil {
+		// The callback reports every file, so the
+		// periodic progress would only repeat it.
+		gonvOpts = append(gonvOpts,
+			gonverge.WithProgressInterval(0),
+			gonverge.WithProgressCallback(func(path string, done, total int) {
+				lg.Infof("[%d/%d] processing %s", done, total, filepath.Base(path))
+			}),
+		)
+	}
 
-	var excludes []regexp.Regexp
-	for _, e := range ex {
+	var excludes []*regexp.Regexp
+	for _, e := range c.exclude {
 		re, err := regexp.Compile(e)
 		if err != nil {
 			return nil, fmt.Errorf("failed to compile regex: %w", err)
 		}
-		excludes = append(excludes, *re)
+		excludes = append(excludes, re)
 	}
 	if len(excludes) > 0 {
 		gonvOpts = append(gonvOpts, gonverge.WithExcludes(excludes))
 	}
 
+	var excludeDirs []*regexp.Regexp
+	for _, e := range c.excludeDirs {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile regex: %w", err)
+		}
+		excludeDirs = append(excludeDirs, re)
+	}
+	if len(excludeDirs) > 0 {
+		gonvOpts = append(gonvOpts, gonverge.WithExcludeDirs(excludeDirs))
+	}
+
 	return gonverge.NewGoFileConverger(gonvOpts...), nil
 }
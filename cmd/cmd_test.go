@@ -0,0 +1,762 @@
+package cmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dannyhinshaw/converge/cmd"
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+func TestRoot_Workers(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+		"file3.go": "package main\nfunc func3() {}",
+	}
+
+	tests := map[string]struct {
+		args []string
+	}{
+		"SingleWorker": {
+			args: []string{"--workers", "1"},
+		},
+		"ManyWorkers": {
+			args: []string{"--workers", "8"},
+		},
+		"DefaultWorkers": {
+			args: []string{"--workers", "0"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			stdout, stderr := executeRoot(t, append(tc.args, "--dir", dir)...)
+
+			r.Empty(stderr)
+			r.Contains(stdout, "func func1() {}")
+			r.Contains(stdout, "func func2() {}")
+			r.Contains(stdout, "func func3() {}")
+		})
+	}
+}
+
+func TestRoot_WorkersPassedToConverger(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+
+	_, stderr := executeRoot(t, "--workers", "2", "--verbose", "--dir", dir)
+	r.Contains(stderr, "Starting 2 consumer workers")
+
+	// Zero keeps the default, which is capped at 32 workers.
+	_, stderr = executeRoot(t, "--workers", "0", "--verbose", "--dir", dir)
+	r.Contains(stderr, fmt.Sprintf("Starting %d consumer workers", min(runtime.NumCPU(), 32)))
+}
+
+func TestRoot_SingleWorkerDeterministic(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+		"file3.go": "package main\nfunc func3() {}",
+		"file4.go": "package main\nfunc func4() {}",
+	})
+
+	first, _ := executeRoot(t, "--workers", "1", "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\nfunc func2() {}\nfunc func3() {}\nfunc func4() {}\n", first)
+	for range 5 {
+		next, _ := executeRoot(t, "--workers", "1", "--dir", dir)
+		r.Equal(first, next)
+	}
+}
+
+func TestRoot_Progress(t *testing.T) {
+	r := require.New(t)
+
+	files := make(map[string]string, 100)
+	for i := range 100 {
+		files[fmt.Sprintf("file%03d.go", i)] = fmt.Sprintf("package main\nfunc func%03d() {}", i)
+	}
+	dir := createTempDirWithFiles(t, files)
+
+	// Progress is logged at info level, below the default.
+	_, stderr := executeRoot(t, "--dir", dir)
+	r.Empty(stderr)
+
+	// With --verbose, every file is reported, so the
+	// periodic progress isn't logged on top of it.
+	_, stderr = executeRoot(t, "--verbose", "--dir", dir)
+	r.Contains(stderr, "[100/100] processing")
+	r.NotContains(stderr, "Processed 100/100 files")
+}
+
+func TestRoot_NoFormatStreaming(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	}
+
+	tests := map[string]struct {
+		args     []string
+		streamed bool
+	}{
+		"NoFormat": {
+			args:     []string{"--no-format"},
+			streamed: true,
+		},
+		"NoFormatWithTransform": {
+			args:     []string{"--no-format", "--sort-declarations"},
+			streamed: false,
+		},
+		"Formatted": {
+			args:     nil,
+			streamed: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			stdout, stderr := executeRoot(t, append(tc.args, "--verbose", "--dir", dir)...)
+			r.Equal(tc.streamed, strings.Contains(stderr, "Streaming unformatted output"))
+			r.Contains(stdout, "func func1() {}")
+			r.Contains(stdout, "func func2() {}")
+		})
+	}
+}
+
+func TestRoot_Packages(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package maintest\nfunc func2() {}",
+		"file3.go": "package util\nfunc func3() {}",
+		"file4.go": "package main\nfunc func4() {}",
+	}
+
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"Main": {
+			args:     []string{"--packages", "main"},
+			expected: "package main\n\nfunc func1() {}\nfunc func4() {}\n",
+		},
+		"Util": {
+			args:     []string{"-p", "util"},
+			expected: "package util\n\nfunc func3() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			stdout, stderr := executeRoot(t, append(tc.args, "--dir", dir)...)
+			r.Empty(stderr)
+			r.Equal(tc.expected, stdout)
+		})
+	}
+}
+
+func TestRoot_Recursive(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	r.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	r.NoError(os.WriteFile(filepath.Join(dir, "sub", "file2.go"), []byte("package main\nfunc func2() {}"), 0o644))
+
+	stdout, _ := executeRoot(t, "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\n", stdout)
+
+	stdout, _ = executeRoot(t, "--recursive", "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\nfunc func2() {}\n", stdout)
+}
+
+func TestRoot_ExcludeDirs(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	r.NoError(os.MkdirAll(filepath.Join(dir, "vendor", "dep"), 0o755))
+	r.NoError(os.WriteFile(filepath.Join(dir, "vendor", "dep", "dep.go"), []byte("package main\nfunc dep() {}"), 0o644))
+
+	stdout, _ := executeRoot(t, "--recursive", "-D", "^vendor$", "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\n", stdout)
+}
+
+func TestRoot_MaxDepth(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file0.go": "package main\nfunc func0() {}",
+	})
+	r.NoError(os.MkdirAll(filepath.Join(dir, "x", "y"), 0o755))
+	r.NoError(os.WriteFile(filepath.Join(dir, "x", "file1.go"), []byte("package main\nfunc func1() {}"), 0o644))
+	r.NoError(os.WriteFile(filepath.Join(dir, "x", "y", "file2.go"), []byte("package main\nfunc func2() {}"), 0o644))
+
+	stdout, _ := executeRoot(t, "--recursive", "--max-depth", "1", "--dir", dir)
+	r.Equal("package main\n\nfunc func0() {}\nfunc func1() {}\n", stdout)
+}
+
+func TestRoot_IncludeTests(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go":      "package main\nfunc func1() {}",
+		"file1_test.go": "package main\nfunc test1() {}",
+	})
+
+	stdout, _ := executeRoot(t, "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\n", stdout)
+
+	for _, flag := range []string{"--include-tests", "-T"} {
+		stdout, _ = executeRoot(t, flag, "--dir", dir)
+		r.Equal("package main\n\nfunc func1() {}\nfunc test1() {}\n", stdout)
+	}
+}
+
+func TestRoot_MaxFiles(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	stdout, _ := executeRoot(t, "--max-files", "2", "--dir", dir)
+	r.Contains(stdout, "func func2() {}")
+
+	_, _, err := execute("--max-files", "1", "--dir", dir)
+	r.ErrorContains(err, "too many files: more than 1 files to converge")
+}
+
+func TestRoot_TimeoutPerFile(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	stdout, stderr := executeRoot(t, "--timeout-per-file", "1m", "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\nfunc func2() {}\n", stdout)
+	r.Empty(stderr)
+
+	_, _, err := execute("--timeout-per-file", "-1s", "--dir", dir)
+	r.ErrorContains(err, "invalid timeout per file -1s: must not be negative")
+}
+
+func TestRoot_FileSize(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"empty.go": "",
+		"file1.go": "package main\nfunc func1() {}",
+		"large.go": "package main\n\nvar large = `" + strings.Repeat("x", 1<<20) + "`\n",
+	})
+
+	stdout, stderr := executeRoot(t, "--list", "--min-file-size", "1", "--max-file-size", "1024", "--dir", dir)
+	r.Empty(stderr)
+	r.Equal(filepath.Join(dir, "file1.go")+"\n", stdout)
+}
+
+func TestRoot_List(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	stdout, stderr := executeRoot(t, "--list", "--dir", dir)
+	r.Empty(stderr)
+	r.Equal(filepath.Join(dir, "file1.go")+"\n"+filepath.Join(dir, "file2.go")+"\n", stdout)
+}
+
+func TestRoot_Append(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	outfile := filepath.Join(t.TempDir(), "out.go")
+	r.NoError(os.WriteFile(outfile, []byte("package main\n\nfunc func0() {}\n"), 0o644))
+
+	_, stderr := executeRoot(t, "--append", "--output", outfile, "--dir", dir)
+	r.Empty(stderr)
+
+	content, err := os.ReadFile(outfile)
+	r.NoError(err)
+	r.Equal("package main\n\nfunc func0() {}\n\nfunc func1() {}\n", string(content))
+}
+
+func TestRoot_Formatter(t *testing.T) {
+	tests := map[string]struct {
+		formatter string
+		stdout    string
+		errMsg    string
+	}{
+		"External": {
+			formatter: "tr a-z A-Z",
+			stdout:    "PACKAGE MAIN\n\nFUNC FUNC1() {}\n",
+		},
+		"Failing": {
+			formatter: "false",
+			errMsg:    `failed to run formatter "false"`,
+		},
+		"Empty": {
+			formatter: " ",
+			errMsg:    "invalid formatter",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+			})
+
+			stdout, stderr, err := execute("--formatter", tc.formatter, "--dir", dir)
+			r.Equal(tc.stdout, stdout)
+			if tc.errMsg == "" {
+				r.NoError(err)
+				r.Empty(stderr)
+			} else {
+				r.ErrorContains(err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestRoot_Tag(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+
+	stdout, stderr := executeRoot(t, "--tag", "linux && amd64", "--dir", dir)
+	r.Empty(stderr)
+	r.Equal("//go:build linux && amd64\n\npackage main\n\nfunc func1() {}\n", stdout)
+}
+
+func TestRoot_InvalidTag(t *testing.T) {
+	r := require.New(t)
+
+	_, _, err := execute("--tag", "linux &&")
+	r.ErrorContains(err, "invalid build tag")
+}
+
+func TestRoot_VerboseProgress(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+
+	_, stderr := executeRoot(t, "--verbose", "--dir", dir)
+	r.Contains(stderr, "[1/1] processing file1.go")
+}
+
+func TestRoot_NoGoFiles(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.txt": "This is a text file",
+	})
+
+	stdout, stderr := executeRoot(t, "--verbose", "--dir", dir)
+	r.Empty(stdout)
+	r.Contains(stderr, "No Go files found in directory")
+}
+
+func TestRoot_LogFormatJSON(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+
+	_, stderr := executeRoot(t, "--verbose", "--log-format", "json", "--dir", dir)
+
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	r.NotEmpty(lines)
+	for _, line := range lines {
+		var entry map[string]any
+		r.NoError(json.Unmarshal([]byte(line), &entry), line)
+		r.Contains(entry, "level")
+		r.Contains(entry, "msg")
+	}
+}
+
+func TestRoot_InvalidLogFormat(t *testing.T) {
+	r := require.New(t)
+
+	_, _, err := execute("--log-format", "xml")
+	r.ErrorContains(err, "invalid log format")
+}
+
+func TestRoot_InvalidWorkers(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc main() {}",
+	})
+
+	stdout, _, err := execute("--workers", "-1", "--dir", dir)
+	r.Empty(stdout)
+	r.ErrorContains(err, "invalid number of workers")
+}
+
+// executeRoot runs the root command with the given arguments
+// and returns everything written to stdout and stderr.
+func executeRoot(t *testing.T, args ...string) (string, string) {
+	t.Helper()
+
+	stdout, stderr, err := execute(args...)
+	if err != nil {
+		t.Fatalf("Failed to execute root command: %v", err)
+	}
+
+	return stdout, stderr
+}
+
+// execute runs the root command with the given arguments and returns
+// everything written to stdout and stderr, along with any error.
+func execute(args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	c := cmd.NewRoot("test")
+	c.SetOut(&stdout)
+	c.SetErr(&stderr)
+	c.SetArgs(args)
+
+	err := c.Execute()
+
+	return stdout.String(), stderr.String(), err
+}
+
+// createTempDirWithFiles creates a temp directory with the given files.
+func createTempDirWithFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for filename, content := range files {
+		fp := filepath.Join(dir, filename)
+		if err := os.WriteFile(fp, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write to temp file: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func TestRoot_LogFile(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	logFile := filepath.Join(t.TempDir(), "converge.log")
+
+	stdout, stderr := executeRoot(t, "--verbose", "--log-file", logFile, "--dir", dir)
+	r.Contains(stdout, "func func1() {}")
+	r.Empty(stderr)
+
+	logs, err := os.ReadFile(logFile)
+	r.NoError(err)
+	r.Contains(string(logs), "[1/1] processing file1.go")
+}
+
+func TestRoot_LogFileFallback(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	logFile := filepath.Join(t.TempDir(), "missing", "converge.log")
+
+	_, stderr := executeRoot(t, "--verbose", "--log-file", logFile, "--dir", dir)
+	r.Contains(stderr, "failed to open log file")
+	r.Contains(stderr, "[1/1] processing file1.go")
+}
+
+func TestRoot_LogFileInfo(t *testing.T) {
+	r := require.New(t)
+
+	// Validation issues are logged at error level.
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func1() {}",
+	})
+
+	_, stderr, err := execute("validate", "--dir", dir)
+	r.Error(err)
+	r.True(strings.HasPrefix(stderr, "[error]"), stderr)
+
+	_, stderr, err = execute("validate", "--log-file-info", "--dir", dir)
+	r.Error(err)
+	r.Regexp(`^validate\.go:\d+: \[error\]`, stderr)
+}
+
+func TestRoot_Checksum(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc func1() { fmt.Println() }",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	// Output to stdout is followed by its checksum on stderr.
+	stdout, stderr := executeRoot(t, "--checksum", "--dir", dir)
+	r.Contains(stdout, "func func2() {}")
+	r.Equal(gonverge.Checksum([]byte(stdout))+"\n", stderr)
+
+	stdout2, stderr2 := executeRoot(t, "--checksum", "--dir", dir)
+	r.Equal(stdout, stdout2)
+	r.Equal(stderr, stderr2)
+
+	// The checksum of an output file is printed to stdout.
+	out := filepath.Join(t.TempDir(), "out.go")
+	stdout, _ = executeRoot(t, "--checksum", "--output", out, "--dir", dir)
+	r.Regexp(`^sha256:[0-9a-f]{64}\n$`, stdout)
+	r.Equal(strings.TrimSpace(stderr), strings.TrimSpace(stdout))
+}
+
+func TestRoot_Quiet(t *testing.T) {
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+
+	tests := map[string]struct {
+		args []string
+	}{
+		"Stdout": {
+			args: []string{"--quiet"},
+		},
+		"Verbose": {
+			args: []string{"-q", "--verbose"},
+		},
+		"Output": {
+			args: []string{"-q", "--verbose", "--output", filepath.Join(t.TempDir(), "out.go")},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			_, stderr := executeRoot(t, append(tc.args, "--dir", dir)...)
+			r.Empty(stderr)
+		})
+	}
+}
+
+func TestRoot_ImportOrder(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport (\n\t\"fmt\"\n\t\"github.com/myorg/pkg\"\n\t\"github.com/other/lib\"\n)\n",
+	})
+
+	stdout, _ := executeRoot(t, "--import-order", "github.com/myorg/,github.com/", "--dir", dir)
+	r.Equal("package main\n\nimport (\n\t\"github.com/myorg/pkg\"\n\n\t\"github.com/other/lib\"\n\n\t\"fmt\"\n)\n", stdout)
+}
+
+func TestRoot_Module(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport (\n\t\"fmt\"\n\t\"github.com/myorg/mylib/pkg\"\n)\n",
+	})
+
+	// The warnings are below the default log level.
+	_, stderr := executeRoot(t, "--module", "github.com/myorg/mylib", "--verbose", "--dir", dir)
+	r.Contains(stderr, `Merged file imports package "pkg" of its own module github.com/myorg/mylib`)
+	r.NotContains(stderr, `Merged file imports package "fmt"`)
+
+	_, stderr = executeRoot(t, "--dir", dir)
+	r.Empty(stderr)
+}
+
+func TestRoot_FileAttribution(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	stdout, _ := executeRoot(t, "--file-attribution", "--dir", dir)
+	r.Equal("package main\n\n// Source: file1.go\n\nfunc func1() {}\n\n// Source: file2.go\n\nfunc func2() {}\n", stdout)
+}
+
+func TestRoot_Separator(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+
+	stdout, _ := executeRoot(t, "--separator", "// --- %s ---", "--dir", dir)
+	r.Equal("package main\n\n// --- file1.go ---\n\nfunc func1() {}\n\n// --- file2.go ---\n\nfunc func2() {}\n", stdout)
+}
+
+func TestRoot_InvalidSeparator(t *testing.T) {
+	r := require.New(t)
+
+	_, _, err := execute("--separator", "// a\n// b")
+	r.ErrorContains(err, "invalid separator")
+}
+
+func TestRoot_Dedup(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc helper() {}",
+		"file2.go": "package main\nfunc helper() {}",
+	})
+
+	stdout, _ := executeRoot(t, "--dedup", "--dir", dir)
+	r.Equal("package main\n\nfunc helper() {}\n", stdout)
+
+	_, _, err := execute("validate", "--dedup", "--dir", dir)
+	r.NoError(err)
+}
+
+func TestRoot_OutputMode(t *testing.T) {
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nimport \"fmt\"\nfunc func1() { fmt.Println() }",
+	})
+
+	tests := map[string]struct {
+		mode     string
+		expected string
+	}{
+		"Full": {
+			mode:     "full",
+			expected: "package main\n\nimport \"fmt\"\n\nfunc func1() { fmt.Println() }\n",
+		},
+		"Snippet": {
+			mode:     "snippet",
+			expected: "import \"fmt\"\n\nfunc func1() { fmt.Println() }\n",
+		},
+		"Declarations": {
+			mode:     "declarations",
+			expected: "func func1() { fmt.Println() }\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			stdout, _ := executeRoot(t, "--output-mode", tc.mode, "--dir", dir)
+			r.Equal(tc.expected, stdout)
+		})
+	}
+}
+
+func TestRoot_InvalidOutputMode(t *testing.T) {
+	tests := map[string]struct {
+		args     []string
+		expected string
+	}{
+		"Unknown": {
+			args:     []string{"--output-mode", "partial"},
+			expected: "invalid output mode",
+		},
+		"WithVet": {
+			args:     []string{"--output-mode", "snippet", "--vet"},
+			expected: "can't be used with --vet or --verify-compile",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			_, _, err := execute(tc.args...)
+			r.ErrorContains(err, tc.expected)
+		})
+	}
+}
+
+func TestRoot_DryRun(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	output := filepath.Join(t.TempDir(), "out.go")
+
+	stdout, _ := executeRoot(t, "--dry-run", "--output", output, "--dir", dir)
+	r.Equal("package main\n\nfunc func1() {}\n", stdout)
+	r.NoFileExists(output)
+}
+
+func TestRoot_Suffix(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package lib\nfunc FooFunc() {}",
+		"file2.go": "package lib\nfunc bar() { FooFunc() }",
+	})
+
+	stdout, _ := executeRoot(t, "--suffix", "V2", "--dir", dir)
+	r.Contains(stdout, "func FooFuncV2() {}")
+	r.Contains(stdout, "{ FooFuncV2() }")
+	r.NotContains(stdout, "FooFunc()")
+}
+
+func TestRoot_InvalidPrefixSuffix(t *testing.T) {
+	tests := map[string]struct {
+		args []string
+		err  string
+	}{
+		"LowerCasePrefix": {
+			args: []string{"--prefix", "v"},
+			err:  `invalid prefix "v": must start with an upper case letter`,
+		},
+		"InvalidPrefix": {
+			args: []string{"--prefix", "V-"},
+			err:  `invalid prefix "V-" or suffix "": renamed names must be valid identifiers`,
+		},
+		"InvalidSuffix": {
+			args: []string{"--suffix", "-2"},
+			err:  `invalid prefix "" or suffix "-2": renamed names must be valid identifiers`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			_, _, err := execute(tc.args...)
+			r.ErrorContains(err, tc.err)
+		})
+	}
+}
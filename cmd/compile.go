@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// compileModFile is the go.mod of the temporary
+// module the converged output is compiled in.
+const compileModFile = "module converge\n\ngo 1.23\n"
+
+// verifyCompile compiles the given Go source on its own in a temporary
+// module, returning an error with the compiler output if it fails. Since
+// the module has no dependencies, only standard library imports resolve.
+// It is canceled with the given context, so the --timeout of the command
+// covers it.
+func verifyCompile(ctx context.Context, src []byte) error {
+	tmpDir, err := os.MkdirTemp("", "converge-compile")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir for go build: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	if err = os.WriteFile(filepath.Join(tmpDir, "go.mod"), []byte(compileModFile), 0o600); err != nil {
+		return fmt.Errorf("failed to write go.mod for go build: %w", err)
+	}
+	if err = os.WriteFile(filepath.Join(tmpDir, "converged.go"), src, 0o600); err != nil {
+		return fmt.Errorf("failed to write temp file for go build: %w", err)
+	}
+
+	c := exec.CommandContext(ctx, "go", "build", "-o", os.DevNull, "./...")
+	c.Dir = tmpDir
+	c.Env = append(os.Environ(), "GOWORK=off")
+
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("merged output does not compile: %w\n%s",
+			err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
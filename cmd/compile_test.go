@@ -0,0 +1,46 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot_VerifyCompile(t *testing.T) {
+	tests := map[string]struct {
+		files  map[string]string
+		stdout string
+		errMsg string
+	}{
+		"Compiles": {
+			files: map[string]string{
+				"file1.go": "package util\nfunc One() int { return 1 }",
+				"file2.go": "package util\nfunc Two() int { return One() + 1 }",
+			},
+			stdout: "package util\n\nfunc One() int { return 1 }\nfunc Two() int { return One() + 1 }\n",
+		},
+		"TypeMismatch": {
+			files: map[string]string{
+				"file1.go": "package util\nfunc One() string { return \"1\" }",
+				"file2.go": "package util\nfunc Two() int { return One() + 1 }",
+			},
+			errMsg: "merged output does not compile",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			stdout, stderr, err := execute("--verify-compile", "--dir", dir)
+			r.Equal(tc.stdout, stdout)
+			if tc.errMsg == "" {
+				r.NoError(err)
+				r.Empty(stderr)
+			} else {
+				r.ErrorContains(err, tc.errMsg)
+			}
+		})
+	}
+}
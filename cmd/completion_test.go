@@ -0,0 +1,46 @@
+package cmd_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		t.Run(shell, func(t *testing.T) {
+			r := require.New(t)
+
+			stdout, _ := executeRoot(t, "completion", shell)
+			r.Contains(stdout, "converge")
+		})
+	}
+}
+
+func TestCompletion_FlagPaths(t *testing.T) {
+	tests := map[string]struct {
+		args      []string
+		directive cobra.ShellCompDirective
+	}{
+		"Dir": {
+			args:      []string{"--dir", ""},
+			directive: cobra.ShellCompDirectiveFilterDirs,
+		},
+		"Output": {
+			args:      []string{"--output", ""},
+			directive: cobra.ShellCompDirectiveFilterFileExt,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			args := append([]string{cobra.ShellCompRequestCmd}, tc.args...)
+			stdout, _ := executeRoot(t, args...)
+			r.Contains(stdout, fmt.Sprintf(":%d\n", tc.directive))
+		})
+	}
+}
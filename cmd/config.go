@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is the config file that is used
+// when it exists in the current working directory
+// and no config file was given with --config.
+const defaultConfigFile = ".converge.yaml"
+
+// applyConfig sets the flags of the given command from the given YAML
+// config file, or from defaultConfigFile if no path is given and it
+// exists. The keys of the config file are the names of the long flags,
+// e.g. "output" or "log-format". Flags that have already been set, on
+// the command line or from the environment, take precedence.
+func applyConfig(c *cobra.Command, path string) error {
+	if path == "" {
+		if _, err := os.Stat(defaultConfigFile); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		path = defaultConfigFile
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var cfg map[string]any
+	if err = yaml.Unmarshal(b, &cfg); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	fs := c.Flags()
+	for _, name := range slices.Sorted(maps.Keys(cfg)) {
+		f := fs.Lookup(name)
+		if f == nil {
+			// Subcommands don't have all the flags of the root
+			// command, but the key must still be a known flag.
+			if c.Root().Flags().Lookup(name) == nil {
+				return fmt.Errorf("unknown key %q in config file %s", name, path)
+			}
+			continue
+		}
+		if f.Changed {
+			continue
+		}
+		if err = setFlag(fs, f, cfg[name]); err != nil {
+			return fmt.Errorf("invalid value for %q in config file %s: %w", name, path, err)
+		}
+	}
+
+	return nil
+}
+
+// setFlag sets the given flag to the given config value.
+// Lists are only accepted for flags that take a list.
+func setFlag(fs *pflag.FlagSet, f *pflag.Flag, val any) error {
+	list, ok := val.([]any)
+	if !ok {
+		return fs.Set(f.Name, fmt.Sprint(val)) //nolint:wrapcheck // Wrapped by the caller.
+	}
+
+	sv, ok := f.Value.(pflag.SliceValue)
+	if !ok {
+		return errors.New("flag does not accept a list")
+	}
+
+	strs := make([]string, len(list))
+	for i, v := range list {
+		strs[i] = fmt.Sprint(v)
+	}
+	if err := sv.Replace(strs); err != nil {
+		return fmt.Errorf("failed to set list: %w", err)
+	}
+	f.Changed = true
+
+	return nil
+}
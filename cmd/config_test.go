@@ -0,0 +1,146 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot_Config(t *testing.T) {
+	files := map[string]string{
+		"file1.go":   "package main\nfunc func1() {}",
+		"exclude.go": "package main\nfunc func2() {}",
+	}
+
+	tests := map[string]struct {
+		config string
+		env    map[string]string
+		args   []string
+		stdout string
+		errMsg string
+	}{
+		"DefaultConfigFile": {
+			config: "dir: {dir}\nexclude:\n  - exclude.go\nworkers: 1\ntimeout: 5s\n",
+			stdout: "package main\n\nfunc func1() {}\n",
+		},
+		"FlagOverridesConfig": {
+			config: "dir: {dir}\nworkers: -1\n",
+			args:   []string{"--workers", "1"},
+			stdout: "package main\n\nfunc func2() {}\nfunc func1() {}\n",
+		},
+		"EnvOverridesConfig": {
+			config: "dir: {dir}\nworkers: -1\n",
+			env:    map[string]string{"CONVERGE_WORKERS": "1"},
+			stdout: "package main\n\nfunc func2() {}\nfunc func1() {}\n",
+		},
+		"ConfigValueUsed": {
+			config: "dir: {dir}\nworkers: -1\n",
+			errMsg: "invalid number of workers",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			// The default config file is read from
+			// the current working directory.
+			cwd := t.TempDir()
+			config := replaceDir(tc.config, dir)
+			r.NoError(os.WriteFile(filepath.Join(cwd, ".converge.yaml"), []byte(config), 0o644))
+			chdir(t, cwd)
+
+			stdout, stderr, err := execute(tc.args...)
+			r.Equal(tc.stdout, stdout)
+			if tc.errMsg == "" {
+				r.NoError(err)
+				r.Empty(stderr)
+			} else {
+				r.ErrorContains(err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestRoot_ConfigFlag(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	config := filepath.Join(t.TempDir(), "converge.yaml")
+	r.NoError(os.WriteFile(config, []byte("dir: "+dir+"\n"), 0o644))
+
+	stdout, stderr := executeRoot(t, "--config", config)
+	r.Empty(stderr)
+	r.Equal("package main\n\nfunc func1() {}\n", stdout)
+}
+
+func TestRoot_ConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		errMsg string
+	}{
+		"UnknownKey": {
+			config: "unknown: true\n",
+			errMsg: `unknown key "unknown"`,
+		},
+		"InvalidValue": {
+			config: "workers: many\n",
+			errMsg: `invalid value for "workers"`,
+		},
+		"ListForScalarFlag": {
+			config: "dir:\n  - a\n  - b\n",
+			errMsg: "flag does not accept a list",
+		},
+		"InvalidYAML": {
+			config: "dir: [\n",
+			errMsg: "failed to parse config file",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			config := filepath.Join(t.TempDir(), "converge.yaml")
+			r.NoError(os.WriteFile(config, []byte(tc.config), 0o644))
+
+			_, _, err := execute("--config", config)
+			r.ErrorContains(err, tc.errMsg)
+		})
+	}
+}
+
+func TestRoot_ConfigFileNotFound(t *testing.T) {
+	r := require.New(t)
+
+	_, _, err := execute("--config", filepath.Join(t.TempDir(), "missing.yaml"))
+	r.ErrorContains(err, "failed to read config file")
+}
+
+// chdir changes the current working directory to the given
+// directory and restores it once the test has finished.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	if err = os.Chdir(dir); err != nil {
+		t.Fatalf("Failed to change working directory: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := os.Chdir(wd); err != nil {
+			t.Fatalf("Failed to restore working directory: %v", err)
+		}
+	})
+}
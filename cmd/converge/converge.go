@@ -5,13 +5,20 @@
 package converge
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 )
 
 // FileConverger is a type that can converge multiple files into one.
@@ -21,9 +28,30 @@ type FileConverger interface {
 	ConvergeFiles(ctx context.Context, dir string, w io.Writer) error
 }
 
+// dirConverger is implemented by file convergers that can converge
+// a directory straight into a file, which they only create once the
+// files are converged, so a failure leaves an existing file intact.
+type dirConverger interface {
+	// ConvergeDir converges all files in the given
+	// directory and writes the result to the given file.
+	ConvergeDir(ctx context.Context, dir, outFile string) error
+}
+
+// maxRetryBackoff is the maximum time to wait between retries,
+// however often the backoff has been doubled.
+const maxRetryBackoff = 30 * time.Second
+
+// resetter is implemented by file convergers that must be
+// reset before they can converge files again after a run.
+type resetter interface {
+	// Reset prepares the file converger to be used again.
+	Reset()
+}
+
 // Command holds the configuration and dependencies for the "converge" command.
 // If a destination file (dst) is specified, it takes precedence over the writer.
 // Otherwise, output defaults to os.Stdout or the provided writer.
+// A Command is not safe for concurrent use; create one per run.
 type Command struct {
 	// dir is the directory to read files from.
 	dir string
@@ -37,6 +65,26 @@ type Command struct {
 
 	// writer is the destination for the output.
 	writer io.Writer
+
+	// appendMode appends the output to the destination
+	// file instead of overwriting it.
+	appendMode bool
+
+	// backup copies an existing destination file
+	// to a backup file before writing to it.
+	backup bool
+
+	// backupTimestamped adds a timestamp to the name of the
+	// backup file, so earlier backups are not overwritten.
+	backupTimestamped bool
+
+	// retries is the maximum number of times to retry
+	// converging the files after a transient failure.
+	retries int
+
+	// backoff is the time to wait before the first
+	// retry, which is doubled for each further retry.
+	backoff time.Duration
 }
 
 // NewCommand returns a new Command with standard defaults.
@@ -70,22 +118,394 @@ func WithDstFile(dst string) Option {
 	}
 }
 
+// WithAppendMode appends the output to the destination file instead of
+// overwriting it. If the destination file already has content, the package
+// clause and imports of the output are stripped since the file has them;
+// it's an error if the output needs imports the file doesn't have or
+// redeclares any of its declarations.
+func WithAppendMode(appendMode bool) Option {
+	return func(c *Command) {
+		c.appendMode = appendMode
+	}
+}
+
+// WithBackup copies an existing destination file to "<dst>.bak"
+// before it is written to, so it can be recovered if needed.
+func WithBackup(backup bool) Option {
+	return func(c *Command) {
+		c.backup = backup
+	}
+}
+
+// WithBackupTimestamped copies an existing destination file to
+// "<dst>.YYYY-MM-DDTHHMMSS.bak" before it is written to. Unlike
+// WithBackup, earlier backups are never overwritten.
+func WithBackupTimestamped(backup bool) Option {
+	return func(c *Command) {
+		c.backupTimestamped = backup
+	}
+}
+
+// WithRetry retries converging the files up to n times after a
+// transient failure, e.g. a file read failing on a network file
+// system. It waits for the given backoff before the first retry
+// and doubles it for each further retry, up to 30 seconds. Only
+// file system errors are retried, except for missing files and
+// permission errors, since other errors like invalid source code
+// would fail again. The output is buffered when retrying, so a
+// failed attempt never writes partial output. The file converger
+// is reset before each retry if it has a Reset method.
+func WithRetry(n int, backoff time.Duration) Option {
+	return func(c *Command) {
+		c.retries = max(n, 0)
+		c.backoff = backoff
+	}
+}
+
 // Run runs the converge command.
-func (c *Command) Run(ctx context.Context) error {
-	if err := c.build(); err != nil {
+func (c *Command) Run(ctx context.Context) (err error) {
+	if err = c.build(); err != nil {
 		return fmt.Errorf("failed to build converge command: %w", err)
 	}
-	if err := c.validate(); err != nil {
+	if err = c.validate(); err != nil {
 		return fmt.Errorf("failed to validate converge command: %w", err)
 	}
-	if err := c.fc.ConvergeFiles(ctx, c.dir, c.writer); err != nil {
+
+	// Only open the destination file once validation has
+	// passed, since opening it truncates any existing file.
+	var appendBody bool
+	if c.dst != "" {
+		if err = c.backupDst(); err != nil {
+			return err
+		}
+
+		// Retrying and appending need the file converger
+		// to write to a buffer or the open file instead.
+		if dc, ok := c.fc.(dirConverger); ok && !c.appendMode && c.retries == 0 {
+			if err = dc.ConvergeDir(ctx, c.dir, c.dst); err != nil {
+				return fmt.Errorf("failed to converge files: %w", err)
+			}
+			return nil
+		}
+
+		var f *os.File
+		if f, appendBody, err = c.openDst(); err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("failed to close destination file %s: %w", c.dst, cerr)
+			}
+		}()
+		c.writer = f
+	}
+
+	if !appendBody {
+		if err = c.convergeFiles(ctx, c.writer); err != nil {
+			return fmt.Errorf("failed to converge files: %w", err)
+		}
+		return nil
+	}
+
+	// The destination file already has a package clause and
+	// imports, so only the declarations are appended to it.
+	var buf bytes.Buffer
+	if err = c.convergeFiles(ctx, &buf); err != nil {
 		return fmt.Errorf("failed to converge files: %w", err)
 	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	dst, err := os.ReadFile(c.dst)
+	if err != nil {
+		return fmt.Errorf("failed to read destination file %s: %w", c.dst, err)
+	}
+	if err = checkAppend(dst, buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to append to destination file %s: %w", c.dst, err)
+	}
+	body, err := stripHeader(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to strip header from converged output: %w", err)
+	}
+	if _, err = c.writer.Write(body); err != nil {
+		return fmt.Errorf("failed to append to destination file %s: %w", c.dst, err)
+	}
+
+	return nil
+}
+
+// Preview runs the full converge pipeline and returns the converged
+// output instead of writing it. The destination file is neither read
+// nor written, so the result can be compared against it, e.g. to check
+// whether a committed merged file is up to date.
+func (c *Command) Preview(ctx context.Context) ([]byte, error) {
+	if err := c.build(); err != nil {
+		return nil, fmt.Errorf("failed to build converge command: %w", err)
+	}
+	if err := validateSrcDir(c.dir); err != nil {
+		return nil, fmt.Errorf("failed to validate converge command: %w", err)
+	}
+	if err := validateSrcReadable(c.dir); err != nil {
+		return nil, fmt.Errorf("failed to validate converge command: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.convergeFiles(ctx, &buf); err != nil {
+		return nil, fmt.Errorf("failed to converge files: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// convergeFiles converges the files of the source directory into
+// the given writer, retrying transient failures if that is enabled.
+func (c *Command) convergeFiles(ctx context.Context, w io.Writer) error {
+	if c.retries == 0 {
+		return c.fc.ConvergeFiles(ctx, c.dir, w) //nolint:wrapcheck // Wrapped by the callers.
+	}
+
+	var buf bytes.Buffer
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		buf.Reset()
+		err := c.fc.ConvergeFiles(ctx, c.dir, &buf)
+		if err == nil {
+			break
+		}
+		if attempt == c.retries || !transient(err) {
+			return err //nolint:wrapcheck // Wrapped by the callers.
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck // Context errors don't need wrapped.
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxRetryBackoff)
+
+		if r, ok := c.fc.(resetter); ok {
+			r.Reset()
+		}
+	}
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to write output: %w", err)
+	}
+
+	return nil
+}
+
+// transient returns true if the given error may be a transient
+// failure, i.e. an error of a file system operation other than
+// the file not existing or missing permissions.
+func transient(err error) bool {
+	var pathErr *fs.PathError
+	switch {
+	case !errors.As(err, &pathErr):
+		return false
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		return false
+	default:
+		return true
+	}
+}
+
+// backupDst copies the destination file to a backup file if backups are
+// enabled. Nothing is copied if the destination file doesn't exist yet.
+func (c *Command) backupDst() (err error) {
+	if !c.backup && !c.backupTimestamped {
+		return nil
+	}
+
+	src, err := os.Open(c.dst)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open destination file %s for backup: %w", c.dst, err)
+	}
+	defer func() { _ = src.Close() }()
+
+	name := c.dst + ".bak"
+	if c.backupTimestamped {
+		name = c.dst + "." + time.Now().Format("2006-01-02T150405") + ".bak"
+	}
+
+	dst, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to create backup file %s: %w", name, err)
+	}
+	defer func() {
+		if cerr := dst.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close backup file %s: %w", name, cerr)
+		}
+	}()
+
+	if _, err = io.Copy(dst, src); err != nil {
+		return fmt.Errorf("failed to copy destination file %s to %s: %w", c.dst, name, err)
+	}
+
 	return nil
 }
 
-// build prepares the command for execution by converting paths to absolute paths,
-// setting up the writer, and ensuring the output destination is valid.
+// openDst opens the destination file for writing. In append mode
+// the file is opened for appending and the returned bool reports
+// whether it already has content; otherwise, it is truncated.
+func (c *Command) openDst() (*os.File, bool, error) {
+	if !c.appendMode {
+		f, err := os.Create(c.dst)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create destination file %s: %w", c.dst, err)
+		}
+		return f, false, nil
+	}
+
+	f, err := os.OpenFile(c.dst, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open destination file %s: %w", c.dst, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, false, fmt.Errorf("failed to stat destination file %s: %w", c.dst, err)
+	}
+
+	return f, info.Size() > 0, nil
+}
+
+// stripHeader removes everything up to and including the package
+// clause and import declarations from the given Go source, leaving
+// only the declarations preceded by a blank line.
+func stripHeader(src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	// With ImportsOnly, the only declarations
+	// parsed are the import declarations.
+	end := f.Name.End()
+	for _, d := range f.Decls {
+		end = max(end, d.End())
+	}
+
+	body := bytes.TrimLeft(src[fset.Position(end).Offset:], "\n")
+
+	return append([]byte("\n"), body...), nil
+}
+
+// checkAppend returns an error if the declarations of the given source
+// can't be appended to the given destination source, since they use
+// imports the destination doesn't have or redeclare its declarations.
+// Appending only adds declarations, so both would fail to compile.
+func checkAppend(dst, src []byte) error {
+	fset := token.NewFileSet()
+	dstFile, err := parser.ParseFile(fset, "", dst, parser.SkipObjectResolution)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination: %w", err)
+	}
+	srcFile, err := parser.ParseFile(fset, "", src, parser.SkipObjectResolution)
+	if err != nil {
+		return fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	imports := make(map[string]struct{})
+	for _, spec := range dstFile.Imports {
+		imports[importSpec(spec)] = struct{}{}
+	}
+	var missing []string
+	for _, spec := range srcFile.Imports {
+		if _, ok := imports[importSpec(spec)]; !ok {
+			missing = append(missing, importSpec(spec))
+		}
+	}
+
+	declared := make(map[string]struct{})
+	for _, name := range topLevelNames(dstFile) {
+		declared[name] = struct{}{}
+	}
+	var redeclared []string
+	for _, name := range topLevelNames(srcFile) {
+		if _, ok := declared[name]; ok {
+			redeclared = append(redeclared, name)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("missing imports: %s", strings.Join(missing, ", ")))
+	}
+	if len(redeclared) > 0 {
+		errs = append(errs, fmt.Errorf("redeclared: %s", strings.Join(redeclared, ", ")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// importSpec returns the given import as written, i.e.
+// its quoted path preceded by its name, if it has one.
+func importSpec(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name + " " + spec.Path.Value
+	}
+	return spec.Path.Value
+}
+
+// topLevelNames returns the names of the top-level declarations of the
+// given file that may only be declared once, i.e. all but init functions
+// and blank identifiers. Methods are named after their receiver type,
+// e.g. "T.String".
+func topLevelNames(f *ast.File) []string {
+	var names []string
+	add := func(name string) {
+		if name != "_" {
+			names = append(names, name)
+		}
+	}
+
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil || len(d.Recv.List) == 0 {
+				if d.Name.Name != "init" {
+					add(d.Name.Name)
+				}
+				continue
+			}
+			recv := d.Recv.List[0].Type
+			if star, ok := recv.(*ast.StarExpr); ok {
+				recv = star.X
+			}
+			switch r := recv.(type) {
+			case *ast.IndexExpr:
+				recv = r.X
+			case *ast.IndexListExpr:
+				recv = r.X
+			}
+			if id, ok := recv.(*ast.Ident); ok {
+				add(id.Name + "." + d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					add(s.Name.Name)
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						add(n.Name)
+					}
+				}
+			}
+		}
+	}
+
+	return names
+}
+
+// build prepares the command for execution by converting paths
+// to absolute paths and setting up the default writer.
 //
 // It must be run before validate since validate depends on these paths.
 func (c *Command) build() error {
@@ -103,14 +523,11 @@ func (c *Command) build() error {
 		return nil
 	}
 
-	// Destination file supplied, so we'll need the
-	// absolute path to it for validation and writing.
+	// Destination file supplied, so we'll need the absolute
+	// path to it for validation and, later, writing.
 	if c.dst, err = filepath.Abs(c.dst); err != nil {
 		return fmt.Errorf("failed to get absolute path to destination file %s: %w", c.dst, err)
 	}
-	if c.writer, err = os.Create(c.dst); err != nil {
-		return fmt.Errorf("failed to create destination file %s: %w", c.dst, err)
-	}
 
 	return nil
 }
@@ -122,7 +539,7 @@ func (c *Command) validate() error {
 	var (
 		merr  error
 		wg    sync.WaitGroup
-		errCh = make(chan error, 1)
+		errCh chan error
 	)
 
 	validators := []func(){
@@ -130,6 +547,10 @@ func (c *Command) validate() error {
 			defer wg.Done()
 			if err := validateSrcDir(c.dir); err != nil {
 				errCh <- err
+				return
+			}
+			if err := validateSrcReadable(c.dir); err != nil {
+				errCh <- err
 			}
 		},
 		func() {
@@ -140,6 +561,9 @@ func (c *Command) validate() error {
 		},
 	}
 
+	// Every validator may fail, so the error channel needs room
+	// for all of their errors; it isn't drained until they're done.
+	errCh = make(chan error, len(validators))
 	for _, fn := range validators {
 		wg.Add(1)
 		go fn()
@@ -160,7 +584,9 @@ func (c *Command) validate() error {
 // and that the user has permission to read from it.
 func validateSrcDir(src string) error {
 	switch srcInfo, err := os.Stat(src); {
-	case err != nil && !os.IsNotExist(err):
+	case os.IsNotExist(err):
+		return fmt.Errorf("source %s does not exist", src)
+	case err != nil:
 		return fmt.Errorf("failed to access source %s: %w", src, err)
 	case err == nil && !srcInfo.IsDir():
 		return fmt.Errorf("source %s is not a directory", src)
@@ -169,6 +595,21 @@ func validateSrcDir(src string) error {
 	}
 }
 
+// validateSrcReadable checks that the entries of the source directory
+// can be listed, so a permission error is reported clearly up front
+// instead of partway through walking the directory.
+func validateSrcReadable(src string) error {
+	_, err := os.ReadDir(src)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("source %s is not readable, check its permissions: %w", src, err)
+	case err != nil:
+		return fmt.Errorf("failed to read source %s: %w", src, err)
+	default:
+		return nil
+	}
+}
+
 // validateDstFile ensures that the destination file is not a directory and
 // checks for write permissions. If the file doesn't exist, no error is returned.
 func validateDstFile(dst string) error {
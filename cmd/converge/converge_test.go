@@ -3,10 +3,17 @@ package converge_test
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
@@ -71,8 +78,8 @@ func TestConverge_Run(t *testing.T) {
 					"exclude.go": "// This file should be excluded",
 				})
 
-				opt := gonverge.WithExcludes([]regexp.Regexp{
-					*reExclude,
+				opt := gonverge.WithExcludes([]*regexp.Regexp{
+					reExclude,
 				})
 
 				fc := gonverge.NewGoFileConverger(opt)
@@ -97,6 +104,101 @@ func TestConverge_Run(t *testing.T) {
 	}
 }
 
+// flakyConverger is a FileConverger that fails
+// with err before it succeeds, a number of times.
+type flakyConverger struct {
+	err      error
+	failures int
+	calls    int
+	resets   int
+}
+
+// ConvergeFiles fails until it has failed the set number of times.
+func (f *flakyConverger) ConvergeFiles(_ context.Context, _ string, w io.Writer) error {
+	f.calls++
+	_, _ = w.Write([]byte(fmt.Sprintf("// attempt %d\n", f.calls)))
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+// Reset counts the resets.
+func (f *flakyConverger) Reset() {
+	f.resets++
+}
+
+func TestConverge_Retry(t *testing.T) {
+	eio := &fs.PathError{Op: "read", Path: "file.go", Err: syscall.EIO}
+
+	tests := map[string]struct {
+		fc      *flakyConverger
+		retries int
+		calls   int
+		output  string
+		err     bool
+	}{
+		"TransientFailure": {
+			fc:      &flakyConverger{err: eio, failures: 1},
+			retries: 3,
+			calls:   2,
+			output:  "// attempt 2\n",
+		},
+		"RetriesExhausted": {
+			fc:      &flakyConverger{err: eio, failures: 5},
+			retries: 2,
+			calls:   3,
+			err:     true,
+		},
+		"NotExist": {
+			fc:      &flakyConverger{err: &fs.PathError{Op: "open", Path: "file.go", Err: fs.ErrNotExist}, failures: 1},
+			retries: 3,
+			calls:   1,
+			err:     true,
+		},
+		"NotFileSystemError": {
+			fc:      &flakyConverger{err: errors.New("syntax error"), failures: 1},
+			retries: 3,
+			calls:   1,
+			err:     true,
+		},
+		"NoRetries": {
+			fc:      &flakyConverger{err: eio, failures: 1},
+			retries: 0,
+			calls:   1,
+			err:     true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+				"file.go": "package main",
+			})
+			defer cleanupSrc()
+
+			var out bytes.Buffer
+			err := converge.NewCommand(tc.fc, srcDir,
+				converge.WithWriter(&out),
+				converge.WithRetry(tc.retries, time.Millisecond),
+			).Run(context.Background())
+			r.Equal(tc.calls, tc.fc.calls)
+			r.Equal(tc.calls-1, tc.fc.resets)
+			if tc.err {
+				r.ErrorIs(err, tc.fc.err)
+				if tc.retries > 0 {
+					r.Empty(out.String())
+				}
+				return
+			}
+			r.NoError(err)
+			r.Equal(tc.output, out.String())
+		})
+	}
+}
+
 func TestConverge_ContextCancellation(t *testing.T) {
 	r := require.New(t)
 
@@ -118,6 +220,350 @@ func TestConverge_ContextCancellation(t *testing.T) {
 	r.ErrorIs(err, context.Canceled)
 }
 
+func TestConverge_ValidationFailurePreservesDstFile(t *testing.T) {
+	r := require.New(t)
+
+	const original = "package main\n\nfunc original() {}\n"
+
+	outFile, cleanupOut := createTempFile(t)
+	defer cleanupOut()
+	r.NoError(os.WriteFile(outFile.Name(), []byte(original), 0o644))
+
+	fc := gonverge.NewGoFileConverger()
+	opt := converge.WithDstFile(outFile.Name())
+	cmdRunner := converge.NewCommand(fc, "/invalid/dir", opt)
+
+	err := cmdRunner.Run(context.Background())
+	r.Error(err)
+
+	content, err := os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Equal(original, string(content))
+}
+
+// dirConvergerFunc is a FileConverger that records whether
+// files were converged with ConvergeFiles or ConvergeDir.
+type dirConvergerFunc struct {
+	// called names the method that was called.
+	called string
+}
+
+// ConvergeFiles records the call and writes a package clause.
+func (d *dirConvergerFunc) ConvergeFiles(_ context.Context, _ string, w io.Writer) error {
+	d.called = "ConvergeFiles"
+	_, err := io.WriteString(w, "package main\n")
+	return err
+}
+
+// ConvergeDir records the call and writes a package clause to the file.
+func (d *dirConvergerFunc) ConvergeDir(_ context.Context, _, outFile string) error {
+	d.called = "ConvergeDir"
+	return os.WriteFile(outFile, []byte("package main\n"), 0o600)
+}
+
+func TestConverge_DstFileUsesConvergeDir(t *testing.T) {
+	tests := map[string]struct {
+		opts     []converge.Option
+		expected string
+	}{
+		"DstFile": {
+			opts:     nil,
+			expected: "ConvergeDir",
+		},
+		"AppendMode": {
+			opts:     []converge.Option{converge.WithAppendMode(true)},
+			expected: "ConvergeFiles",
+		},
+		"Retry": {
+			opts:     []converge.Option{converge.WithRetry(1, time.Millisecond)},
+			expected: "ConvergeFiles",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc main() {}",
+			})
+			defer cleanupSrc()
+
+			dst := filepath.Join(t.TempDir(), "out.go")
+			fc := &dirConvergerFunc{}
+			cmdRunner := converge.NewCommand(fc, srcDir, append(tc.opts, converge.WithDstFile(dst))...)
+			r.NoError(cmdRunner.Run(context.Background()))
+			r.Equal(tc.expected, fc.called)
+
+			content, err := os.ReadFile(dst)
+			r.NoError(err)
+			r.Equal("package main\n", string(content))
+		})
+	}
+}
+
+func TestConverge_ConvergeFailurePreservesDstFile(t *testing.T) {
+	r := require.New(t)
+
+	const original = "package main\n\nfunc original() {}\n"
+
+	// Without any Go files, converging fails.
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"doc.txt": "not a Go file",
+	})
+	defer cleanupSrc()
+
+	outFile, cleanupOut := createTempFile(t)
+	defer cleanupOut()
+	r.NoError(os.WriteFile(outFile.Name(), []byte(original), 0o644))
+
+	fc := gonverge.NewGoFileConverger(gonverge.WithAllowEmpty(false))
+	cmdRunner := converge.NewCommand(fc, srcDir, converge.WithDstFile(outFile.Name()))
+	r.Error(cmdRunner.Run(context.Background()))
+
+	content, err := os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Equal(original, string(content))
+}
+
+func TestConverge_AppendMode(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc appended() { fmt.Println() }",
+	})
+	defer cleanupSrc()
+
+	outFile, cleanupOut := createTempFile(t)
+	defer cleanupOut()
+	r.NoError(os.WriteFile(outFile.Name(), []byte("package main\n\nimport \"fmt\"\n\nfunc original() { fmt.Println() }\n"), 0o644))
+
+	fc := gonverge.NewGoFileConverger()
+	cmdRunner := converge.NewCommand(fc, srcDir,
+		converge.WithDstFile(outFile.Name()),
+		converge.WithAppendMode(true),
+	)
+	r.NoError(cmdRunner.Run(context.Background()))
+
+	content, err := os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Contains(string(content), "func original() { fmt.Println() }")
+	r.Contains(string(content), "func appended() { fmt.Println() }")
+	r.Equal(1, strings.Count(string(content), "package "))
+	r.Equal(1, strings.Count(string(content), "import "))
+}
+
+func TestConverge_AppendModeConflicts(t *testing.T) {
+	original := "package main\n\nimport \"strings\"\n\ntype T struct{}\n\nfunc (T) String() string { return strings.ToUpper(\"t\") }\n"
+
+	tests := map[string]struct {
+		src string
+		err string
+	}{
+		"MissingImport": {
+			src: "package main\n\nimport \"fmt\"\n\nfunc appended() { fmt.Println() }",
+			err: "missing imports: \"fmt\"",
+		},
+		"RenamedImport": {
+			src: "package main\n\nimport s \"strings\"\n\nvar upper = s.ToUpper",
+			err: "missing imports: s \"strings\"",
+		},
+		"Redeclared": {
+			src: "package main\n\ntype T int\n\nfunc (T) String() string { return \"\" }",
+			err: "redeclared: T, T.String",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{"file1.go": tc.src})
+			defer cleanupSrc()
+
+			outFile, cleanupOut := createTempFile(t)
+			defer cleanupOut()
+			r.NoError(os.WriteFile(outFile.Name(), []byte(original), 0o644))
+
+			fc := gonverge.NewGoFileConverger()
+			cmdRunner := converge.NewCommand(fc, srcDir,
+				converge.WithDstFile(outFile.Name()),
+				converge.WithAppendMode(true),
+			)
+			r.ErrorContains(cmdRunner.Run(context.Background()), tc.err)
+
+			// Nothing is appended to the destination file.
+			content, err := os.ReadFile(outFile.Name())
+			r.NoError(err)
+			r.Equal(original, string(content))
+		})
+	}
+}
+
+func TestConverge_AppendModeEmptyDstFile(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc appended() {}",
+	})
+	defer cleanupSrc()
+
+	outFile, cleanupOut := createTempFile(t)
+	defer cleanupOut()
+
+	fc := gonverge.NewGoFileConverger()
+	cmdRunner := converge.NewCommand(fc, srcDir,
+		converge.WithDstFile(outFile.Name()),
+		converge.WithAppendMode(true),
+	)
+	r.NoError(cmdRunner.Run(context.Background()))
+
+	content, err := os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Equal("package main\n\nfunc appended() {}\n", string(content))
+}
+
+func TestConverge_Preview(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc func1() { fmt.Println() }",
+		"file2.go": "package main\n\nfunc func2() {}",
+	})
+	defer cleanupSrc()
+
+	outFile, cleanupOut := createTempFile(t)
+	defer cleanupOut()
+
+	preview, err := converge.NewCommand(gonverge.NewGoFileConverger(), srcDir,
+		converge.WithDstFile(outFile.Name()),
+	).Preview(context.Background())
+	r.NoError(err)
+
+	// Previewing must not touch the destination file.
+	content, err := os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Empty(content)
+
+	cmdRunner := converge.NewCommand(gonverge.NewGoFileConverger(), srcDir,
+		converge.WithDstFile(outFile.Name()),
+	)
+	r.NoError(cmdRunner.Run(context.Background()))
+
+	content, err = os.ReadFile(outFile.Name())
+	r.NoError(err)
+	r.Equal(string(content), string(preview))
+}
+
+func TestConverge_Backup(t *testing.T) {
+	const original = "package main\n\nfunc original() {}\n"
+
+	tests := map[string]struct {
+		opt     converge.Option
+		pattern string
+	}{
+		"Backup": {
+			opt:     converge.WithBackup(true),
+			pattern: ".bak",
+		},
+		"BackupTimestamped": {
+			opt:     converge.WithBackupTimestamped(true),
+			pattern: ".*.bak",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc converged() {}",
+			})
+			defer cleanupSrc()
+
+			outFile := filepath.Join(t.TempDir(), "merged.go")
+			r.NoError(os.WriteFile(outFile, []byte(original), 0o644))
+
+			fc := gonverge.NewGoFileConverger()
+			cmdRunner := converge.NewCommand(fc, srcDir, converge.WithDstFile(outFile), tc.opt)
+			r.NoError(cmdRunner.Run(context.Background()))
+
+			backups, err := filepath.Glob(outFile + tc.pattern)
+			r.NoError(err)
+			r.Len(backups, 1)
+
+			content, err := os.ReadFile(backups[0])
+			r.NoError(err)
+			r.Equal(original, string(content))
+
+			content, err = os.ReadFile(outFile)
+			r.NoError(err)
+			r.Contains(string(content), "func converged() {}")
+		})
+	}
+}
+
+func TestConverge_BackupNoDstFile(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc converged() {}",
+	})
+	defer cleanupSrc()
+
+	outFile := filepath.Join(t.TempDir(), "merged.go")
+
+	fc := gonverge.NewGoFileConverger()
+	cmdRunner := converge.NewCommand(fc, srcDir, converge.WithDstFile(outFile), converge.WithBackup(true))
+	r.NoError(cmdRunner.Run(context.Background()))
+
+	r.NoFileExists(outFile + ".bak")
+	r.FileExists(outFile)
+}
+
+func TestConverge_UnreadableSrcDir(t *testing.T) {
+	r := require.New(t)
+
+	if os.Getuid() <= 0 {
+		t.Skip("directory permissions are not enforced for root or on this platform")
+	}
+
+	dir, cleanup := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	defer cleanup()
+	r.NoError(os.Chmod(dir, 0))
+	defer func() { _ = os.Chmod(dir, 0o755) }()
+
+	var buf bytes.Buffer
+	fc := gonverge.NewGoFileConverger()
+	err := converge.NewCommand(fc, dir, converge.WithWriter(&buf)).Run(context.Background())
+	r.ErrorIs(err, fs.ErrPermission)
+	r.ErrorContains(err, "source "+dir+" is not readable, check its permissions")
+	r.Zero(buf.Len())
+}
+
+func TestConverge_ValidationJoinsAllErrors(t *testing.T) {
+	r := require.New(t)
+
+	// Both the source directory and the destination file
+	// are invalid, so both validators fail at once.
+	dst := t.TempDir()
+	fc := gonverge.NewGoFileConverger()
+	cmdRunner := converge.NewCommand(fc, "/invalid/dir", converge.WithDstFile(dst))
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- cmdRunner.Run(context.Background()) }()
+
+	select {
+	case err := <-errCh:
+		r.ErrorContains(err, "source /invalid/dir does not exist")
+		r.ErrorContains(err, "is a directory")
+	case <-time.After(5 * time.Second):
+		r.FailNow("Run did not return when all validators failed")
+	}
+}
+
 // createTempFile creates a single temp file, returning the file pointer and a cleanup function.
 func createTempFile(t *testing.T) (*os.File, func()) {
 	t.Helper()
@@ -0,0 +1,117 @@
+package converge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"runtime"
+	"slices"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// FileConvergerFactory creates a new FileConverger. A FileConverger
+// may only be used for a single run, so a MultiDirConverger creates
+// a new one for each directory it converges.
+type FileConvergerFactory func() (FileConverger, error)
+
+// MultiDirConverger converges multiple source directories into separate
+// destination files, e.g. all the independent packages of a monorepo.
+// The directories are converged in parallel, up to a concurrency limit.
+type MultiDirConverger struct {
+	// newFC creates the file converger
+	// for each source directory.
+	newFC FileConvergerFactory
+
+	// dirs maps each source directory to
+	// the destination file to write it to.
+	dirs map[string]string
+
+	// concurrency is the maximum number of
+	// directories to converge at the same time.
+	concurrency int
+
+	// opts are the options applied to the
+	// Command run for each source directory.
+	opts []Option
+}
+
+// NewMultiDirConverger returns a new MultiDirConverger that converges
+// each source directory in dirs into its destination file.
+func NewMultiDirConverger(newFC FileConvergerFactory, dirs map[string]string, opts ...MultiDirOption) *MultiDirConverger {
+	m := MultiDirConverger{
+		newFC:       newFC,
+		dirs:        dirs,
+		concurrency: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return &m
+}
+
+// MultiDirOption is a function that configures a MultiDirConverger.
+type MultiDirOption func(*MultiDirConverger)
+
+// WithConcurrency sets the maximum number of directories
+// to converge at the same time. Values below 1 are ignored.
+func WithConcurrency(n int) MultiDirOption {
+	return func(m *MultiDirConverger) {
+		if n > 0 {
+			m.concurrency = n
+		}
+	}
+}
+
+// WithCommandOptions sets the options applied to the Command run for
+// each source directory. The destination file is always set from the
+// directory mapping, so WithDstFile and WithWriter have no effect.
+func WithCommandOptions(opts ...Option) MultiDirOption {
+	return func(m *MultiDirConverger) {
+		m.opts = opts
+	}
+}
+
+// Run converges all source directories into their destination files.
+// A failure to converge one directory doesn't stop the others, and
+// the errors of all failed directories are joined into one.
+func (m *MultiDirConverger) Run(ctx context.Context) error {
+	var (
+		mu   sync.Mutex
+		merr error
+		g    errgroup.Group
+	)
+	g.SetLimit(m.concurrency)
+
+	for _, dir := range slices.Sorted(maps.Keys(m.dirs)) {
+		dst := m.dirs[dir]
+		g.Go(func() error {
+			if err := m.run(ctx, dir, dst); err != nil {
+				mu.Lock()
+				merr = errors.Join(merr, err)
+				mu.Unlock()
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return merr
+}
+
+// run converges the given source directory into the given destination file.
+func (m *MultiDirConverger) run(ctx context.Context, dir, dst string) error {
+	fc, err := m.newFC()
+	if err != nil {
+		return fmt.Errorf("failed to create file converger for %s: %w", dir, err)
+	}
+
+	opts := append(slices.Clone(m.opts), WithDstFile(dst))
+	if err = NewCommand(fc, dir, opts...).Run(ctx); err != nil {
+		return fmt.Errorf("failed to converge %s into %s: %w", dir, dst, err)
+	}
+
+	return nil
+}
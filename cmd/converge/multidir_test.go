@@ -0,0 +1,66 @@
+package converge_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dannyhinshaw/converge/cmd/converge"
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+func TestMultiDirConverger_Run(t *testing.T) {
+	r := require.New(t)
+
+	outDir := t.TempDir()
+	dirs := make(map[string]string)
+	for i := range 3 {
+		srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+			"file.go": fmt.Sprintf("package pkg%d\nfunc func%d() {}", i, i),
+		})
+		defer cleanupSrc()
+
+		dirs[srcDir] = filepath.Join(outDir, fmt.Sprintf("pkg%d.go", i))
+	}
+
+	m := converge.NewMultiDirConverger(newGoFileConverger, dirs, converge.WithConcurrency(3))
+	r.NoError(m.Run(context.Background()))
+
+	for i := range 3 {
+		content, err := os.ReadFile(filepath.Join(outDir, fmt.Sprintf("pkg%d.go", i)))
+		r.NoError(err)
+		r.Equal(fmt.Sprintf("package pkg%d\n\nfunc func%d() {}\n", i, i), string(content))
+	}
+}
+
+func TestMultiDirConverger_AggregatesErrors(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nfunc main() {}",
+	})
+	defer cleanupSrc()
+
+	outDir := t.TempDir()
+	dirs := map[string]string{
+		srcDir:         filepath.Join(outDir, "ok.go"),
+		"/invalid/one": filepath.Join(outDir, "one.go"),
+		"/invalid/two": filepath.Join(outDir, "two.go"),
+	}
+
+	m := converge.NewMultiDirConverger(newGoFileConverger, dirs)
+	err := m.Run(context.Background())
+	r.ErrorContains(err, "/invalid/one does not exist")
+	r.ErrorContains(err, "/invalid/two does not exist")
+	r.FileExists(filepath.Join(outDir, "ok.go"))
+}
+
+// newGoFileConverger is a converge.FileConvergerFactory
+// that creates a GoFileConverger with default options.
+func newGoFileConverger() (converge.FileConverger, error) {
+	return gonverge.NewGoFileConverger(), nil
+}
@@ -0,0 +1,78 @@
+package converge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// pipelineFile is the name of the file the output of each
+// pipeline stage is written to for the next stage to read.
+const pipelineFile = "converged.go"
+
+// PipelineConverger is a FileConverger that chains multiple FileConvergers,
+// e.g. to merge the files, strip their comments, and then add a header.
+// The first stage converges the source directory. Since a FileConverger
+// reads a directory, the output of each stage is written to a file in a
+// temp directory that the next stage converges, and the last stage
+// writes to the output.
+type PipelineConverger struct {
+	// stages are the file convergers
+	// to run, in order.
+	stages []FileConverger
+}
+
+// NewPipelineConverger returns a new PipelineConverger
+// that runs the given stages in order.
+func NewPipelineConverger(stages ...FileConverger) *PipelineConverger {
+	return &PipelineConverger{stages: stages}
+}
+
+// ConvergeFiles runs each stage of the pipeline in order, starting
+// with the given directory, and writes the output of the last stage
+// to the given writer.
+func (p *PipelineConverger) ConvergeFiles(ctx context.Context, dir string, w io.Writer) (err error) {
+	if len(p.stages) == 0 {
+		return errors.New("pipeline has no stages")
+	}
+
+	var tmpDir string
+	if len(p.stages) > 1 {
+		if tmpDir, err = os.MkdirTemp("", "converge-pipeline-*"); err != nil {
+			return fmt.Errorf("failed to create pipeline temp dir: %w", err)
+		}
+		defer func() {
+			if rerr := os.RemoveAll(tmpDir); rerr != nil && err == nil {
+				err = fmt.Errorf("failed to remove pipeline temp dir %s: %w", tmpDir, rerr)
+			}
+		}()
+	}
+
+	var buf bytes.Buffer
+	src := dir
+	for i, fc := range p.stages {
+		if i == len(p.stages)-1 {
+			if err = fc.ConvergeFiles(ctx, src, w); err != nil {
+				return fmt.Errorf("failed to run pipeline stage %d: %w", i+1, err)
+			}
+			break
+		}
+
+		buf.Reset()
+		if err = fc.ConvergeFiles(ctx, src, &buf); err != nil {
+			return fmt.Errorf("failed to run pipeline stage %d: %w", i+1, err)
+		}
+
+		out := filepath.Join(tmpDir, pipelineFile)
+		if err = os.WriteFile(out, buf.Bytes(), 0o600); err != nil {
+			return fmt.Errorf("failed to write output of pipeline stage %d: %w", i+1, err)
+		}
+		src = tmpDir
+	}
+
+	return nil
+}
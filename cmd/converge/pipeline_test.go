@@ -0,0 +1,112 @@
+package converge_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/dannyhinshaw/converge/cmd/converge"
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+// convergerFunc is a FileConverger that calls the function.
+type convergerFunc func(ctx context.Context, dir string, w io.Writer) error
+
+// ConvergeFiles calls the function.
+func (f convergerFunc) ConvergeFiles(ctx context.Context, dir string, w io.Writer) error {
+	return f(ctx, dir, w)
+}
+
+// readDir returns the contents of all files in the given directory.
+func readDir(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func TestPipelineConverger_ConvergeFiles(t *testing.T) {
+	r := require.New(t)
+
+	srcDir, cleanupSrc := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\n// one does things.\nfunc one() {}",
+		"file2.go": "package main\n\n// two does things.\nfunc two() {}",
+	})
+	defer cleanupSrc()
+
+	var called [3]bool
+	merge := convergerFunc(func(ctx context.Context, dir string, w io.Writer) error {
+		called[0] = true
+		return gonverge.NewGoFileConverger().ConvergeFiles(ctx, dir, w)
+	})
+
+	reComment := regexp.MustCompile(`//.*`)
+	upper := convergerFunc(func(_ context.Context, dir string, w io.Writer) error {
+		called[1] = true
+		src, err := readDir(dir)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(reComment.ReplaceAllFunc(src, bytes.ToUpper))
+		return err
+	})
+
+	var count int
+	counter := convergerFunc(func(_ context.Context, dir string, w io.Writer) error {
+		called[2] = true
+		src, err := readDir(dir)
+		if err != nil {
+			return err
+		}
+		count, err = w.Write(src)
+		return err
+	})
+
+	var out bytes.Buffer
+	p := converge.NewPipelineConverger(merge, upper, counter)
+	r.NoError(p.ConvergeFiles(context.Background(), srcDir, &out))
+
+	r.Equal([3]bool{true, true, true}, called)
+	r.Equal(out.Len(), count)
+	r.Contains(out.String(), "// ONE DOES THINGS.\nfunc one() {}")
+	r.Contains(out.String(), "// TWO DOES THINGS.\nfunc two() {}")
+}
+
+func TestPipelineConverger_StageError(t *testing.T) {
+	r := require.New(t)
+
+	var called bool
+	failing := convergerFunc(func(context.Context, string, io.Writer) error {
+		return errors.New("boom")
+	})
+	next := convergerFunc(func(context.Context, string, io.Writer) error {
+		called = true
+		return nil
+	})
+
+	p := converge.NewPipelineConverger(failing, next)
+	err := p.ConvergeFiles(context.Background(), t.TempDir(), io.Discard)
+	r.ErrorContains(err, "failed to run pipeline stage 1: boom")
+	r.False(called)
+
+	p = converge.NewPipelineConverger()
+	r.Error(p.ConvergeFiles(context.Background(), t.TempDir(), io.Discard))
+}
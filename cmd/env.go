@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// envPrefix is the prefix of environment variables
+// that provide defaults for command-line flags.
+const envPrefix = "CONVERGE_"
+
+// envFlags maps environment variables to the names
+// of the command-line flags they provide defaults for.
+var envFlags = map[string]string{
+	envPrefix + "DIR":     "dir",
+	envPrefix + "OUTPUT":  "output",
+	envPrefix + "TIMEOUT": "timeout",
+	envPrefix + "VERBOSE": "verbose",
+	envPrefix + "WORKERS": "workers",
+}
+
+// applyEnv sets the flags of the given command from their environment
+// variables. Flags explicitly set on the command line take precedence,
+// and flags the command doesn't have (e.g. output for subcommands)
+// are ignored.
+func applyEnv(c *cobra.Command) error {
+	fs := c.Flags()
+	for env, name := range envFlags {
+		val, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+
+		f := fs.Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		if err := fs.Set(name, val); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", val, env, err)
+		}
+	}
+
+	return nil
+}
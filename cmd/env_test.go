@@ -0,0 +1,96 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot_EnvDefaults(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	}
+
+	tests := map[string]struct {
+		env   map[string]string
+		args  []string
+		check func(r *require.Assertions, dir, stdout, stderr string, err error)
+	}{
+		"Dir": {
+			env: map[string]string{"CONVERGE_DIR": "{dir}"},
+			check: func(r *require.Assertions, _, stdout, _ string, err error) {
+				r.NoError(err)
+				r.Equal("package main\n\nfunc func1() {}\n", stdout)
+			},
+		},
+		"Output": {
+			env:  map[string]string{"CONVERGE_OUTPUT": "{dir}/out.go"},
+			args: []string{"--dir", "{dir}"},
+			check: func(r *require.Assertions, dir, stdout, _ string, err error) {
+				r.NoError(err)
+				r.Empty(stdout)
+				r.FileExists(filepath.Join(dir, "out.go"))
+			},
+		},
+		"Verbose": {
+			env:  map[string]string{"CONVERGE_VERBOSE": "true"},
+			args: []string{"--dir", "{dir}"},
+			check: func(r *require.Assertions, _, _, stderr string, err error) {
+				r.NoError(err)
+				r.Contains(stderr, "Verbose logging enabled.")
+			},
+		},
+		"Workers": {
+			env:  map[string]string{"CONVERGE_WORKERS": "-1"},
+			args: []string{"--dir", "{dir}"},
+			check: func(r *require.Assertions, _, stdout, _ string, err error) {
+				r.Empty(stdout)
+				r.ErrorContains(err, "invalid number of workers")
+			},
+		},
+		"FlagTakesPrecedence": {
+			env:  map[string]string{"CONVERGE_WORKERS": "-1"},
+			args: []string{"--workers", "1", "--dir", "{dir}"},
+			check: func(r *require.Assertions, _, stdout, stderr string, err error) {
+				r.NoError(err)
+				r.Empty(stderr)
+				r.Equal("package main\n\nfunc func1() {}\n", stdout)
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			for k, v := range tc.env {
+				t.Setenv(k, replaceDir(v, dir))
+			}
+			args := make([]string, len(tc.args))
+			for i, arg := range tc.args {
+				args[i] = replaceDir(arg, dir)
+			}
+
+			stdout, stderr, err := execute(args...)
+			tc.check(r, dir, stdout, stderr, err)
+		})
+	}
+}
+
+func TestRoot_EnvInvalidTimeout(t *testing.T) {
+	r := require.New(t)
+
+	t.Setenv("CONVERGE_TIMEOUT", "soon")
+
+	_, _, err := execute("--dir", os.TempDir())
+	r.ErrorContains(err, "CONVERGE_TIMEOUT")
+}
+
+// replaceDir replaces the "{dir}" placeholder in s with dir.
+func replaceDir(s, dir string) string {
+	return strings.ReplaceAll(s, "{dir}", dir)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+// externalFormatter returns a gonverge.FormatFunc that formats Go source
+// by piping it through the given command, e.g. "gofumpt" or "goimports".
+// The command may include arguments, separated by whitespace, and is
+// killed if the given context is done before it finishes.
+func externalFormatter(ctx context.Context, command string) (gonverge.FormatFunc, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invalid formatter %q: must not be empty", command)
+	}
+
+	return func(src []byte) ([]byte, error) {
+		var stdout, stderr bytes.Buffer
+		c := exec.CommandContext(ctx, args[0], args[1:]...) //nolint:gosec // Running the user's formatter is the point.
+		c.Stdin = bytes.NewReader(src)
+		c.Stdout = &stdout
+		c.Stderr = &stderr
+
+		if err := c.Run(); err != nil {
+			return nil, fmt.Errorf("failed to run formatter %q: %w: %s",
+				command, err, strings.TrimSpace(stderr.String()))
+		}
+
+		return stdout.Bytes(), nil
+	}, nil
+}
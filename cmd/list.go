@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+const (
+	// listFormatPlain is the list format that prints one file per line.
+	listFormatPlain = "plain"
+
+	// listFormatJSON is the list format that prints a JSON array of files.
+	listFormatJSON = "json"
+)
+
+// newListCmd creates the list subcommand, which prints the files that
+// would be merged without merging them. It shares the persistent flags
+// of the root command.
+func newListCmd(rootCmd *cmd) *cobra.Command {
+	var format string
+	c := cobra.Command{
+		Use:   "list [flags]",
+		Short: "Print the Go source files that would be merged",
+		Long: `
+List prints the absolute path of every Go source file that would be merged,
+without merging them. By default one path is printed per line, which makes
+the output easy to pipe into other tools such as 'wc -l' or 'xargs'. Use
+--format json to print a JSON array of paths instead.
+`,
+		Args:         cobra.MaximumNArgs(0),
+		SilenceUsage: true,
+		PreRunE: func(*cobra.Command, []string) error {
+			switch format {
+			case listFormatPlain, listFormatJSON:
+				return nil
+			default:
+				return fmt.Errorf("invalid list format %q: must be %q or %q",
+					format, listFormatPlain, listFormatJSON)
+			}
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel, _ := rootCmd.setup(cmd, "listCmd")
+			defer cancel()
+
+			converger, err := createConverger(ctx, rootCmd.lg.WithName("converger"), rootCmd)
+			if err != nil {
+				return fmt.Errorf("failed to create converger: %w", err)
+			}
+
+			return rootCmd.listFiles(ctx, converger, format)
+		},
+	}
+
+	c.Flags().StringVarP(&format,
+		"format", "f", listFormatPlain,
+		"Output format, either 'plain' or 'json'",
+	)
+
+	return &c
+}
+
+// listFiles prints the files that the converger would process
+// as absolute paths, in the given list format.
+func (c *cmd) listFiles(ctx context.Context, converger *gonverge.GoFileConverger, format string) error {
+	dir, err := filepath.Abs(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path to source directory %s: %w", c.dir, err)
+	}
+
+	files, err := converger.ListFiles(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	if format == listFormatJSON {
+		if files == nil {
+			files = []string{}
+		}
+		if err = json.NewEncoder(c.stdout).Encode(files); err != nil {
+			return fmt.Errorf("failed to write file list: %w", err)
+		}
+		return nil
+	}
+
+	for _, f := range files {
+		if _, err = fmt.Fprintln(c.stdout, f); err != nil {
+			return fmt.Errorf("failed to write file list: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// listImports prints the sorted, unique import paths of the
+// files that the converger would process, one per line.
+func (c *cmd) listImports(ctx context.Context, converger *gonverge.GoFileConverger) error {
+	imports, err := converger.Imports(ctx, c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to collect imports: %w", err)
+	}
+
+	for _, imp := range imports {
+		if _, err = fmt.Fprintln(c.stdout, imp); err != nil {
+			return fmt.Errorf("failed to write import list: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestList(t *testing.T) {
+	files := map[string]string{
+		"file1.go":   "package main\nfunc func1() {}",
+		"file2.go":   "package main\nfunc func2() {}",
+		"exclude.go": "package main\nfunc exclude() {}",
+		"file.txt":   "This is a text file",
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		stdout, _ := executeRoot(t, "list", "--dir", dir, "--exclude", "exclude.go")
+
+		expected := filepath.Join(dir, "file1.go") + "\n" + filepath.Join(dir, "file2.go") + "\n"
+		r.Equal(expected, stdout)
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		stdout, _ := executeRoot(t, "list", "--dir", dir, "--exclude", "exclude.go", "--format", "json")
+
+		var paths []string
+		r.NoError(json.Unmarshal([]byte(stdout), &paths))
+		r.Equal([]string{
+			filepath.Join(dir, "file1.go"),
+			filepath.Join(dir, "file2.go"),
+		}, paths)
+	})
+
+	t.Run("InvalidFormat", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		_, _, err := execute("list", "--dir", dir, "--format", "xml")
+		r.ErrorContains(err, "invalid list format")
+	})
+}
+
+func TestPrintImports(t *testing.T) {
+	r := require.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nimport (\n\t\"os\"\n\t\"fmt\"\n)\nfunc func1() { fmt.Println(os.Args) }",
+		"file2.go": "package main\nimport f \"fmt\"\nfunc func2() { f.Println() }",
+		"file3.go": "package main\nimport (\n\t\"strings\"\n\t_ \"embed\"\n)\nvar s = strings.ToUpper",
+	})
+	output := filepath.Join(t.TempDir(), "out.go")
+
+	stdout, _ := executeRoot(t, "--print-imports", "--dir", dir, "--output", output)
+	r.Equal("embed\nfmt\nos\nstrings\n", stdout)
+	r.NoFileExists(output)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dannyhinshaw/converge/cmd/converge"
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+// report is the JSON report printed with --json,
+// which describes the converged output.
+type report struct {
+	// Package is the name of the converged package.
+	Package string `json:"package"`
+
+	// Files are the absolute paths of the files converged.
+	Files []string `json:"files"`
+
+	// Imports are the unique import paths of the output.
+	Imports []string `json:"imports"`
+
+	// Declarations are the names of the
+	// top-level declarations of the output.
+	Declarations []string `json:"declarations"`
+
+	// Bytes is the size of the output.
+	Bytes int `json:"bytes"`
+
+	// Source is the output itself, if it is included,
+	// which encoding/json encodes as base64.
+	Source []byte `json:"source,omitempty"`
+}
+
+// printReport prints a JSON report describing the
+// output of the converger instead of the output.
+func (c *cmd) printReport(ctx context.Context, converger *gonverge.GoFileConverger) error {
+	dir, err := filepath.Abs(c.dir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path to source directory %s: %w", c.dir, err)
+	}
+
+	src, err := converge.NewCommand(converger, dir).Preview(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to converge files: %w", err)
+	}
+
+	// The report describes the output itself, so it
+	// reflects the transformations applied to it.
+	pkg, err := gonverge.ParsePackage(src)
+	if err != nil {
+		return fmt.Errorf("failed to parse converged source: %w", err)
+	}
+
+	files, err := converger.ListFiles(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("failed to list files: %w", err)
+	}
+
+	r := report{
+		Package:      pkg.Name,
+		Files:        files,
+		Imports:      make([]string, 0, len(pkg.Imports)),
+		Declarations: pkg.DeclarationNames(),
+		Bytes:        len(src),
+	}
+	for _, imp := range pkg.Imports {
+		// The path is the last field of the import spec,
+		// after the name of the import, if there is one.
+		fields := strings.Fields(imp)
+		path, err := strconv.Unquote(fields[len(fields)-1])
+		if err != nil {
+			return fmt.Errorf("failed to unquote import %s: %w", imp, err)
+		}
+		r.Imports = append(r.Imports, path)
+	}
+	if r.Declarations == nil {
+		r.Declarations = []string{}
+	}
+	if c.jsonIncludeSource {
+		r.Source = src
+	}
+
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(r); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package cmd_test
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONReport(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(greeting) }",
+		"file2.go": "package main\n\nimport s \"strings\"\n\nvar greeting, name = s.ToUpper(\"hi\"), \"x\"\n\n" +
+			"type T struct{}\n\nfunc (T) String() string { return name }",
+	}
+	expected := map[string]any{
+		"package":      "main",
+		"imports":      []any{"fmt", "strings"},
+		"declarations": []any{"main", "greeting", "name", "T", "T.String"},
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		stdout, _ := executeRoot(t, "--json", "--dir", dir)
+
+		var report map[string]any
+		r.NoError(json.Unmarshal([]byte(stdout), &report))
+		r.Equal(expected["package"], report["package"])
+		r.Equal(expected["imports"], report["imports"])
+		r.Equal(expected["declarations"], report["declarations"])
+		r.Equal([]any{filepath.Join(dir, "file1.go"), filepath.Join(dir, "file2.go")}, report["files"])
+		r.NotContains(report, "source")
+
+		// The report has the size of the output it replaces.
+		source, _ := executeRoot(t, "--dir", dir)
+		r.InDelta(len(source), report["bytes"], 0)
+	})
+
+	t.Run("TransformedOutput", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		stdout, _ := executeRoot(t, "--json", "--prefix", "X", "--sort-declarations", "--dir", dir)
+
+		var report map[string]any
+		r.NoError(json.Unmarshal([]byte(stdout), &report))
+		r.Equal([]any{"greeting", "name", "XT", "XT.String", "main"}, report["declarations"])
+	})
+
+	t.Run("IncludeSource", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		stdout, _ := executeRoot(t, "--json", "--json-include-source", "--dir", dir)
+
+		var report struct {
+			Bytes  int    `json:"bytes"`
+			Source []byte `json:"source"`
+		}
+		r.NoError(json.Unmarshal([]byte(stdout), &report))
+
+		source, _ := executeRoot(t, "--dir", dir)
+		r.Equal(source, string(report.Source))
+		r.Equal(len(source), report.Bytes)
+	})
+
+	t.Run("IncludeSourceWithoutJSON", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		_, _, err := execute("--json-include-source", "--dir", dir)
+		r.ErrorContains(err, "--json-include-source can only be used with --json")
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		r := require.New(t)
+
+		dir := createTempDirWithFiles(t, files)
+		_, _, err := execute("--json", "--output", filepath.Join(dir, "out.go"), "--dir", dir)
+		r.ErrorContains(err, "none of the others can be")
+	})
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/dannyhinshaw/converge/internal/gonverge"
+)
+
+// newValidateCmd creates the validate subcommand, which checks that the
+// Go files in the source directory can be merged without writing any
+// output. It shares the persistent flags of the root command.
+func newValidateCmd(rootCmd *cmd) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [flags]",
+		Short: "Check that Go source files can be merged without writing output",
+		Long: `
+Validate checks that the Go source files in a directory can be merged into a
+single file, without writing any output. This makes it suitable as a CI gate.
+
+It verifies that all files declare the same package, that the merged source
+is valid Go, and that no top-level declaration is duplicated. Each issue is
+reported on its own line and the command exits with a non-zero exit code if
+any issue is found.
+`,
+		Args:         cobra.MaximumNArgs(0),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx, cancel, lg := rootCmd.setup(cmd, "validateCmd")
+			defer cancel()
+
+			lg.Info("Starting validate operation...")
+			return rootCmd.validate(ctx)
+		},
+	}
+}
+
+// validate checks that the files in the source directory can be
+// converged, logging each issue found as a separate error.
+func (c *cmd) validate(ctx context.Context) error {
+	converger, err := createConverger(ctx, c.lg.WithName("converger"), c)
+	if err != nil {
+		return fmt.Errorf("failed to create converger: %w", err)
+	}
+
+	err = converger.Validate(ctx, c.dir)
+	if err == nil {
+		c.lg.Infof("Successfully validated '%s'.", c.dir)
+		return nil
+	}
+	if !errors.Is(err, gonverge.ErrValidation) {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+
+	var issues int
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, issue := range joined.Unwrap() {
+			if errors.Is(issue, gonverge.ErrValidation) {
+				continue
+			}
+			c.lg.Error(issue)
+			issues++
+		}
+	}
+
+	return fmt.Errorf("validation of '%s' found %d issue(s)", c.dir, issues)
+}
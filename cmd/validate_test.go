@@ -0,0 +1,59 @@
+package cmd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		files  map[string]string
+		errMsg string
+		issues []string
+	}{
+		"Valid": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file2.go": "package main\nfunc func2() {}",
+			},
+		},
+		"DuplicateFunction": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file2.go": "package main\nfunc func1() {}",
+			},
+			errMsg: "found 1 issue(s)",
+			issues: []string{"duplicate declaration: func1"},
+		},
+		"InconsistentPackages": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file2.go": "package other\nfunc func2() {}",
+			},
+			errMsg: "found 1 issue(s)",
+			issues: []string{"inconsistent package names: main, other"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			stdout, stderr, err := execute("validate", "--dir", dir)
+			r.Empty(stdout)
+
+			if tc.errMsg == "" {
+				r.NoError(err)
+				r.Empty(stderr)
+				return
+			}
+
+			r.ErrorContains(err, tc.errMsg)
+			for _, issue := range tc.issues {
+				r.Contains(stderr, issue)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// vetFile runs go vet on the given Go file, returning an
+// error with the reported issues if go vet finds any. It
+// is canceled with the given context, so the --timeout of
+// the command covers it.
+func vetFile(ctx context.Context, path string) error {
+	// Run from the file's directory, so go vet
+	// resolves imports from the module it's in.
+	c := exec.CommandContext(ctx, "go", "vet", filepath.Base(path))
+	c.Dir = filepath.Dir(path)
+
+	out, err := c.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("go vet reported issues in %s: %w\n%s",
+			path, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package cmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot_Vet(t *testing.T) {
+	tests := map[string]struct {
+		files  map[string]string
+		output bool
+		stdout string
+		errMsg string
+	}{
+		"Clean": {
+			files: map[string]string{
+				"file1.go": "package main\nimport \"fmt\"\nfunc main() { fmt.Println(\"hi\") }",
+			},
+			stdout: "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\") }\n",
+		},
+		"Issues": {
+			files: map[string]string{
+				"file1.go": "package main\nimport \"fmt\"\nfunc main() { _ = fmt.Sprintf(\"%d\", \"hi\") }",
+			},
+			errMsg: "go vet reported issues",
+		},
+		"IssuesInOutputFile": {
+			files: map[string]string{
+				"file1.go": "package main\nimport \"fmt\"\nfunc main() { _ = fmt.Sprintf(\"%d\", \"hi\") }",
+			},
+			output: true,
+			errMsg: "go vet reported issues",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			args := []string{"--vet", "--dir", dir}
+			if tc.output {
+				args = append(args, "--output", filepath.Join(t.TempDir(), "out.go"))
+			}
+
+			stdout, stderr, err := execute(args...)
+			r.Equal(tc.stdout, stdout)
+			if tc.errMsg == "" {
+				r.NoError(err)
+				r.Empty(stderr)
+			} else {
+				r.ErrorContains(err, tc.errMsg)
+				r.ErrorContains(err, "Sprintf format %d has arg")
+			}
+		})
+	}
+}
+
+func TestRoot_VetTempFileRemoved(t *testing.T) {
+	r := require.New(t)
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc main() {}",
+	})
+	_, stderr := executeRoot(t, "--vet", "--dir", dir)
+	r.Empty(stderr)
+
+	entries, err := os.ReadDir(tmp)
+	r.NoError(err)
+	r.Empty(entries)
+}
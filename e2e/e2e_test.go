@@ -0,0 +1,114 @@
+// Package e2e_test runs the converge binary as a black box
+// against the fixture directories in testdata/fixtures.
+package e2e_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureDir is the fixture directory with the
+// Go files that the integration tests converge.
+var fixtureDir = filepath.Join("testdata", "fixtures", "basic")
+
+// expectedFile is the converged output of fixtureDir. It has a
+// .golden extension so it is not converged with the fixtures.
+var expectedFile = filepath.Join(fixtureDir, "expected.go.golden")
+
+func TestMain_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test in short mode")
+	}
+
+	bin := buildBinary(t)
+	expected, err := os.ReadFile(expectedFile)
+	require.NoError(t, err)
+
+	t.Run("Basic", func(t *testing.T) {
+		r := require.New(t)
+
+		stdout, _, err := run(bin, "--dir", fixtureDir)
+		r.NoError(err)
+		r.Equal(string(expected), stdout)
+	})
+
+	t.Run("Exclude", func(t *testing.T) {
+		r := require.New(t)
+
+		stdout, _, err := run(bin, "--dir", fixtureDir, "--exclude", "^zz_")
+		r.NoError(err)
+		r.Contains(stdout, "func greeting() string {")
+		r.NotContains(stdout, "var generated = true")
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		r := require.New(t)
+
+		out := filepath.Join(t.TempDir(), "out.go")
+		stdout, _, err := run(bin, "--dir", fixtureDir, "--output", out)
+		r.NoError(err)
+		r.Empty(stdout)
+
+		b, err := os.ReadFile(out)
+		r.NoError(err)
+		r.Equal(string(expected), string(b))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		r := require.New(t)
+
+		stdout, _, err := run(bin, "--dir", fixtureDir, "--timeout", "1m")
+		r.NoError(err)
+		r.Equal(string(expected), stdout)
+
+		_, stderr, err := run(bin, "--dir", fixtureDir, "--timeout", "1ns")
+		var exitErr *exec.ExitError
+		r.True(errors.As(err, &exitErr), "expected an exit error, got: %v", err)
+		r.Equal(1, exitErr.ExitCode())
+		r.Contains(stderr, "context deadline exceeded")
+	})
+
+	t.Run("Verbose", func(t *testing.T) {
+		r := require.New(t)
+
+		stdout, stderr, err := run(bin, "--dir", fixtureDir, "--verbose")
+		r.NoError(err)
+		r.Equal(string(expected), stdout)
+		r.Contains(stderr, "Verbose logging enabled.")
+		r.Contains(stderr, "processing greeting.go")
+	})
+}
+
+// buildBinary builds the converge binary into a
+// temp directory and returns the path to it.
+func buildBinary(t *testing.T) string {
+	t.Helper()
+
+	bin := filepath.Join(t.TempDir(), "converge")
+	c := exec.Command("go", "build", "-o", bin, ".")
+	c.Dir = ".."
+	if out, err := c.CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build binary: %v\n%s", err, out)
+	}
+
+	return bin
+}
+
+// run runs the binary with the given arguments
+// and returns what it wrote to stdout and stderr.
+func run(bin string, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	c := exec.Command(bin, args...)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	err := c.Run()
+
+	return stdout.String(), stderr.String(), err
+}
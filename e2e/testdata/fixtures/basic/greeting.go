@@ -0,0 +1,8 @@
+package main
+
+import "strings"
+
+// greeting returns the greeting to print.
+func greeting() string {
+	return strings.ToUpper("hello")
+}
@@ -0,0 +1,4 @@
+package main
+
+// generated is excluded with --exclude in the e2e tests.
+var generated = true
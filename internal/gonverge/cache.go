@@ -0,0 +1,70 @@
+package gonverge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCache caches the processed goFile of each file by its path, so
+// a converger that converges the same directory again, e.g. after
+// Reset, doesn't read and process files that haven't changed since.
+type fileCache struct {
+	// mu guards entries, since the
+	// consumers use the cache concurrently.
+	mu sync.Mutex
+
+	// entries maps the paths of processed
+	// files to their cached results.
+	entries map[string]cacheEntry
+}
+
+// cacheEntry is a processed file along with
+// the file info it was processed with.
+type cacheEntry struct {
+	// modTime is the modification time of the file.
+	modTime time.Time
+
+	// size is the size of the file in bytes.
+	size int64
+
+	// gf is the processed file.
+	gf *goFile
+}
+
+// newFileCache returns a new, empty fileCache.
+func newFileCache() *fileCache {
+	return &fileCache{entries: make(map[string]cacheEntry)}
+}
+
+// wrap returns a processFunc that returns the cached goFile of a file
+// if its modification time and size are unchanged since it was cached,
+// and otherwise processes it with the given processFunc and caches it.
+func (fc *fileCache) wrap(proc processFunc) processFunc {
+	return func(ctx context.Context, path string) (*goFile, error) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat file: %w", err)
+		}
+
+		fc.mu.Lock()
+		e, ok := fc.entries[path]
+		fc.mu.Unlock()
+		if ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+			return e.gf, nil
+		}
+
+		gf, err := proc(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		fc.mu.Lock()
+		fc.entries[path] = cacheEntry{modTime: info.ModTime(), size: info.Size(), gf: gf}
+		fc.mu.Unlock()
+
+		return gf, nil
+	}
+}
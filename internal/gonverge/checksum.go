@@ -0,0 +1,15 @@
+package gonverge
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Checksum returns the SHA-256 hash of the given converged output
+// in the form "sha256:<hex>". Converging the same files with the
+// same options always produces the same checksum, which makes it
+// easy to verify that the output is reproducible.
+func Checksum(src []byte) string {
+	sum := sha256.Sum256(src)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
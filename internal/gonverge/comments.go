@@ -0,0 +1,133 @@
+package gonverge
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// stripComments removes the comments from the given Go source. If
+// docOnly is true, only the doc comments of exported top-level
+// declarations are removed. Compiler directives such as //go:embed
+// are always kept since removing them changes the meaning of the code.
+//
+// The source is printed from its syntax tree after the comments have
+// been removed, so the result is always formatted in gofmt style.
+func stripComments(src []byte, docOnly bool) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	var strip map[*ast.CommentGroup]struct{}
+	if docOnly {
+		strip = exportedDocs(f)
+	}
+
+	comments := f.Comments[:0]
+	for _, cg := range f.Comments {
+		if docOnly {
+			if _, ok := strip[cg]; !ok {
+				comments = append(comments, cg)
+				continue
+			}
+		}
+		if cg = directives(cg); cg != nil {
+			comments = append(comments, cg)
+		}
+	}
+	f.Comments = comments
+
+	// The printer falls back to the comment fields of the nodes
+	// when the file has no comments left, so clear them as well.
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.File:
+			n.Doc = nil
+		case *ast.FuncDecl:
+			n.Doc = nil
+		case *ast.GenDecl:
+			n.Doc = nil
+		case *ast.TypeSpec:
+			n.Doc, n.Comment = nil, nil
+		case *ast.ValueSpec:
+			n.Doc, n.Comment = nil, nil
+		case *ast.ImportSpec:
+			n.Doc, n.Comment = nil, nil
+		case *ast.Field:
+			n.Doc, n.Comment = nil, nil
+		}
+		return true
+	})
+
+	var buf bytes.Buffer
+	cfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err = cfg.Fprint(&buf, fset, f); err != nil {
+		return nil, fmt.Errorf("failed to print source: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// exportedDocs returns the set of doc comments
+// of exported top-level declarations in the file.
+func exportedDocs(f *ast.File) map[*ast.CommentGroup]struct{} {
+	docs := make(map[*ast.CommentGroup]struct{})
+	add := func(cg *ast.CommentGroup) {
+		if cg != nil {
+			docs[cg] = struct{}{}
+		}
+	}
+
+	for _, d := range f.Decls {
+		switch d := d.(type) {
+		case *ast.FuncDecl:
+			if d.Name.IsExported() {
+				add(d.Doc)
+			}
+		case *ast.GenDecl:
+			var exported bool
+			for _, s := range d.Specs {
+				switch s := s.(type) {
+				case *ast.TypeSpec:
+					if s.Name.IsExported() {
+						exported = true
+						add(s.Doc)
+					}
+				case *ast.ValueSpec:
+					if slices.ContainsFunc(s.Names, (*ast.Ident).IsExported) {
+						exported = true
+						add(s.Doc)
+					}
+				}
+			}
+			if exported {
+				add(d.Doc)
+			}
+		}
+	}
+
+	return docs
+}
+
+// directives returns a comment group holding only the compiler
+// directives of the given group, or nil if it has none.
+func directives(cg *ast.CommentGroup) *ast.CommentGroup {
+	var list []*ast.Comment
+	for _, c := range cg.List {
+		if strings.HasPrefix(c.Text, "//go:") {
+			list = append(list, c)
+		}
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	return &ast.CommentGroup{List: list}
+}
@@ -0,0 +1,250 @@
+package gonverge
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+)
+
+// declRank determines where a declaration is
+// placed when sorting declarations by name.
+type declRank int
+
+const (
+	// declRankDefault is the rank for all declarations that
+	// are not init or main functions, sorted by their name.
+	declRankDefault declRank = iota
+
+	// declRankInit is the rank for init functions,
+	// which are sorted after all other declarations.
+	declRankInit
+
+	// declRankMain is the rank for the main function,
+	// which is always the very last declaration.
+	declRankMain
+)
+
+// decl is a top-level declaration in Go source code
+// along with any comments directly preceding it.
+type decl struct {
+	// name identifies the declaration. Methods are
+	// prefixed with their receiver type, e.g. "T.Method".
+	name string
+
+	// rank determines the position of the
+	// declaration when sorting by name.
+	rank declRank
+
+	// names are all names declared by the declaration,
+	// which is more than one for grouped declarations.
+	names []string
+
+	// src is the source code of the declaration,
+	// including any comments preceding it.
+	src []byte
+}
+
+// splitSource is Go source code split into its top-level declarations.
+type splitSource struct {
+	// header is everything up to and including the
+	// package clause and import declarations.
+	header []byte
+
+	// decls are the top-level declarations, excluding imports.
+	decls []decl
+
+	// trailer is anything after the last
+	// declaration, e.g. trailing comments.
+	trailer []byte
+}
+
+// splitDecls splits the given Go source into its header,
+// top-level declarations and trailer. The source is split
+// on line boundaries so that comments preceding a declaration
+// stay attached to it when the declarations are reordered.
+func splitDecls(src []byte) (*splitSource, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+	tf := fset.File(f.Pos())
+
+	// The header ends after the package clause, or
+	// after the last import declaration if any.
+	end := lineEnd(src, tf.Offset(f.Name.End()))
+
+	var ss splitSource
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			end = lineEnd(src, tf.Offset(gd.End()))
+			continue
+		}
+		if ss.header == nil {
+			ss.header = src[:end]
+		}
+
+		start := end
+		end = lineEnd(src, tf.Offset(d.End()))
+		name, rank := declName(d)
+		ss.decls = append(ss.decls, decl{
+			name:  name,
+			rank:  rank,
+			names: namesOf(d),
+			src:   src[start:end],
+		})
+	}
+	if ss.header == nil {
+		ss.header = src[:end]
+	}
+	ss.trailer = src[end:]
+
+	return &ss, nil
+}
+
+// bytes joins the split source back together, separating
+// each declaration from the next with a blank line.
+func (ss *splitSource) bytes() []byte {
+	var buf bytes.Buffer
+	buf.Write(bytes.TrimRight(ss.header, "\n"))
+	buf.WriteString("\n")
+	for _, d := range ss.decls {
+		buf.WriteString("\n")
+		buf.Write(bytes.Trim(d.src, "\n"))
+		buf.WriteString("\n")
+	}
+	buf.Write(ss.trailer)
+
+	return buf.Bytes()
+}
+
+// sortDeclarations sorts the top-level declarations of the given
+// Go source alphabetically by name. Init functions and the main
+// function are sorted to the end, in that order.
+func sortDeclarations(src []byte) ([]byte, error) {
+	ss, err := splitDecls(src)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(ss.decls, func(a, b decl) int {
+		return cmp.Or(
+			cmp.Compare(a.rank, b.rank),
+			cmp.Compare(strings.ToLower(a.name), strings.ToLower(b.name)),
+			cmp.Compare(a.name, b.name),
+		)
+	})
+
+	return ss.bytes(), nil
+}
+
+// dedupDeclarations removes every top-level declaration of the given
+// Go source whose names have all been declared before, keeping the first
+// occurrence. Grouped declarations that only partially overlap with
+// earlier ones are kept, since removing them would drop other names.
+// It returns the deduplicated source and the names of removed declarations.
+func dedupDeclarations(src []byte) ([]byte, []string, error) {
+	ss, err := splitDecls(src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		removed []string
+		seen    = make(map[string]struct{})
+		decls   = ss.decls[:0]
+	)
+	for _, d := range ss.decls {
+		dup := len(d.names) > 0
+		for _, name := range d.names {
+			if _, ok := seen[name]; !ok {
+				dup = false
+			}
+		}
+		if dup {
+			removed = append(removed, d.name)
+			continue
+		}
+		for _, name := range d.names {
+			seen[name] = struct{}{}
+		}
+		decls = append(decls, d)
+	}
+	if len(removed) == 0 {
+		return src, nil, nil
+	}
+	ss.decls = decls
+
+	return ss.bytes(), removed, nil
+}
+
+// declName returns the name and sort rank of the given declaration.
+func declName(d ast.Decl) (string, declRank) {
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return recvTypeName(d.Recv.List[0].Type) + "." + d.Name.Name, declRankDefault
+		}
+		switch d.Name.Name {
+		case "init":
+			return d.Name.Name, declRankInit
+		case "main":
+			return d.Name.Name, declRankMain
+		default:
+			return d.Name.Name, declRankDefault
+		}
+	case *ast.GenDecl:
+		if len(d.Specs) == 0 {
+			return "", declRankDefault
+		}
+		switch s := d.Specs[0].(type) {
+		case *ast.TypeSpec:
+			return s.Name.Name, declRankDefault
+		case *ast.ValueSpec:
+			return s.Names[0].Name, declRankDefault
+		}
+	}
+
+	return "", declRankDefault
+}
+
+// recvTypeName returns the name of the type of a method receiver.
+func recvTypeName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return recvTypeName(e.X)
+	case *ast.IndexExpr:
+		return recvTypeName(e.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(e.X)
+	case *ast.Ident:
+		return e.Name
+	default:
+		return ""
+	}
+}
+
+// lineEnd returns the offset just past the end of the line containing
+// the given offset, as long as the rest of that line is blank or a
+// comment. Otherwise, the given offset is returned unchanged.
+func lineEnd(src []byte, offset int) int {
+	i := bytes.IndexByte(src[offset:], '\n')
+	if i < 0 {
+		i = len(src) - offset
+	}
+
+	rest := strings.TrimSpace(string(src[offset : offset+i]))
+	if rest != "" && !strings.HasPrefix(rest, "//") {
+		return offset
+	}
+	if offset+i < len(src) {
+		i++ // Include the newline.
+	}
+
+	return offset + i
+}
@@ -1,8 +1,13 @@
 package gonverge
 
 import (
+	"bytes"
+	"cmp"
 	"fmt"
-	"go/format"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -12,13 +17,46 @@ import (
 // This struct is used to aggregate multiple Go files into
 // a single file, maintaining proper syntax and formatting.
 type goFile struct {
+	// srcPath is the path of the source file this
+	// goFile was processed from, if there was one.
+	srcPath string
+
 	// pkgName is the name of the package
 	// that the file belongs to.
 	pkgName string
 
+	// pkgNames is the set of all package names
+	// declared by the files merged into this one.
+	pkgNames map[string]struct{}
+
 	// imports is a set of all imports for the file.
 	imports map[string]struct{}
 
+	// sources maps the names of top-level declarations
+	// to the path of the file that declared them first.
+	sources map[string]string
+
+	// duplicates maps the names of top-level declarations
+	// declared by more than one merged file to the paths
+	// of all of those files, in merge order.
+	duplicates map[string][]string
+
+	// inits are the paths of the files that declare an
+	// init function, once for each init function.
+	inits []string
+
+	// files is the number of source
+	// files merged into this one.
+	files int
+
+	// skipped are the paths of the Go files that
+	// were found but excluded from merging.
+	skipped []string
+
+	// importOrder are the path prefixes of the import groups,
+	// in order. Imports matching none of them come last.
+	importOrder []string
+
 	// code is the literal code for the file.
 	code strings.Builder
 }
@@ -26,15 +64,20 @@ type goFile struct {
 // newGoFile returns a new goFile instance.
 func newGoFile() *goFile {
 	return &goFile{
-		imports: make(map[string]struct{}),
+		pkgNames:   make(map[string]struct{}),
+		imports:    make(map[string]struct{}),
+		sources:    make(map[string]string),
+		duplicates: make(map[string][]string),
 	}
 }
 
 // addImport adds the given import to the set of imports,
 // ensuring no duplicate imports are added. This is important
 // when merging multiple Go files that may have overlapping dependencies.
+// Surrounding whitespace is trimmed so the same import is only added once
+// and buildImports can control the spacing.
 func (f *goFile) addImport(importLine string) {
-	f.imports[importLine] = struct{}{}
+	f.imports[strings.TrimSpace(importLine)] = struct{}{}
 }
 
 // appendCode adds a line of Go code to the current file. Each
@@ -44,17 +87,46 @@ func (f *goFile) appendCode(code string) {
 	f.code.WriteString("\n")
 }
 
-// merge merges the given goFile into the result
-// by adding the imports and appending the code.
-func (f *goFile) merge(gf *goFile) {
+// merge merges the given goFile into the result by adding
+// the imports and appending the code. The code is preceded
+// by the given comment lines, if any.
+func (f *goFile) merge(gf *goFile, comments ...string) {
 	if f.pkgName == "" {
 		f.pkgName = gf.pkgName
 	}
+	if gf.pkgName != "" {
+		f.pkgNames[gf.pkgName] = struct{}{}
+	}
+	f.files++
+	f.inits = append(f.inits, gf.inits...)
 
 	for imp := range gf.imports {
 		f.addImport(imp)
 	}
 
+	for name, path := range gf.sources {
+		first, ok := f.sources[name]
+		if !ok {
+			f.sources[name] = path
+			continue
+		}
+		if len(f.duplicates[name]) == 0 {
+			f.duplicates[name] = []string{first}
+		}
+		f.duplicates[name] = append(f.duplicates[name], path)
+	}
+
+	// The comments are surrounded by blank lines so they are never
+	// mistaken for the doc comment of the following declaration.
+	if len(comments) > 0 {
+		f.code.WriteString("\n")
+		for _, c := range comments {
+			f.code.WriteString(c)
+			f.code.WriteString("\n")
+		}
+		f.code.WriteString("\n")
+	}
+
 	f.code.WriteString(gf.code.String())
 }
 
@@ -74,10 +146,15 @@ func (f *goFile) buildImports() string {
 		}
 	} else {
 		builder.WriteString("import (\n")
-		for imp := range f.imports {
-			builder.WriteString("\t")
-			builder.WriteString(imp)
-			builder.WriteString("\n")
+		for i, group := range f.importGroups() {
+			if i > 0 {
+				builder.WriteString("\n")
+			}
+			for _, imp := range group {
+				builder.WriteString("\t")
+				builder.WriteString(imp)
+				builder.WriteString("\n")
+			}
 		}
 		builder.WriteString(")\n")
 	}
@@ -85,33 +162,111 @@ func (f *goFile) buildImports() string {
 	return builder.String()
 }
 
-// FormatCode formats the code in the goFile and returns the result.
-func (f *goFile) FormatCode() ([]byte, error) {
-	// Use a strings.Builder to build
-	// the newly converged Go file.
-	var builder strings.Builder
+// importGroups returns the imports split into groups by the import
+// order, each sorted by import path. An import belongs to the group
+// of the first prefix it matches, or to the last group if it matches
+// none. Empty groups are left out.
+func (f *goFile) importGroups() [][]string {
+	group := func(imp string) int {
+		path := importPath(imp)
+		for i, prefix := range f.importOrder {
+			if strings.HasPrefix(path, prefix) {
+				return i
+			}
+		}
+		return len(f.importOrder)
+	}
 
-	// Write the package name.
-	builder.WriteString("package ")
-	builder.WriteString(f.pkgName)
-	builder.WriteString("\n\n")
+	imports := slices.SortedFunc(maps.Keys(f.imports), func(a, b string) int {
+		return cmp.Or(
+			cmp.Compare(group(a), group(b)),
+			cmp.Compare(importPath(a), importPath(b)),
+			cmp.Compare(a, b),
+		)
+	})
 
-	// Write the imports.
-	if len(f.imports) > 0 {
-		imports := f.buildImports()
-		builder.WriteString(imports)
+	var groups [][]string
+	for i, imp := range imports {
+		if i == 0 || group(imp) != group(imports[i-1]) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], imp)
 	}
 
-	// Write the code.
-	builder.WriteString(f.code.String())
+	return groups
+}
 
-	// Use go/format to format the code in standard gofmt style.
-	// Note(@danny): We should also allow the user to specify
-	// using gofumpt or other formatters.
-	b, err := format.Source([]byte(builder.String()))
+// moduleRelPath returns the given import path relative to the given
+// module path, or false if the import is not within the module. The
+// module's root package is returned as ".".
+func moduleRelPath(module, path string) (string, bool) {
+	if path == module {
+		return ".", true
+	}
+	rel, ok := strings.CutPrefix(path, module+"/")
+	if !ok || rel == "" {
+		return "", false
+	}
+	return rel, true
+}
+
+// importPath returns the unquoted path of the given import
+// line, which may have an alias, or an empty string if the
+// line has no valid import path.
+func importPath(imp string) string {
+	// The path is the first quoted string of the import,
+	// after the alias if there is one.
+	i := strings.IndexAny(imp, "\"`")
+	if i < 0 {
+		return ""
+	}
+	quoted, err := strconv.QuotedPrefix(imp[i:])
 	if err != nil {
-		return nil, fmt.Errorf("failed to format code: %w", err)
+		return ""
+	}
+	path, err := strconv.Unquote(quoted)
+	if err != nil {
+		return ""
+	}
+
+	return path
+}
+
+// source returns the unformatted source code for the goFile.
+func (f *goFile) source() []byte {
+	var buf bytes.Buffer
+	buf.Grow(f.code.Len())
+
+	// Writing to a bytes.Buffer never fails.
+	_ = f.writeCode(&buf)
+
+	return buf.Bytes()
+}
+
+// writeCode writes the unformatted source code for the goFile
+// to the given writer. The code is written straight from the
+// builder it was merged into, without copying it in memory.
+//
+// Exactly one blank line separates the package clause, the
+// imports and the code, however the code of the first merged
+// file started.
+func (f *goFile) writeCode(w io.Writer) error {
+	// The code is written without the blank lines it starts with.
+	code := strings.TrimLeft(f.code.String(), " \t\r\n")
+
+	// Write the package name and imports.
+	header := "package " + f.pkgName + "\n\n" + f.buildImports()
+	if len(f.imports) > 0 && code != "" {
+		header += "\n"
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return fmt.Errorf("failed to write package clause and imports: %w", err)
+	}
+
+	// Write the code.
+	if _, err := io.WriteString(w, code); err != nil {
+		return fmt.Errorf("failed to write code: %w", err)
 	}
 
-	return b, nil
+	return nil
 }
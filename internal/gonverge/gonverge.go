@@ -4,12 +4,27 @@
 package gonverge
 
 import (
+	"bytes"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"go/build/constraint"
+	"go/format"
+	"go/parser"
+	"go/token"
 	"io"
+	"maps"
+	"os"
+	"path/filepath"
 	"regexp"
 	"runtime"
-	"sync"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 
 	"github.com/dannyhinshaw/converge/internal/olog"
 )
@@ -17,7 +32,26 @@ import (
 // maxWorkers is the maximum amount of workers to use for processing files.
 const maxWorkers = 32
 
-// debugLogger represents a logger that only logs debug messages.
+// defaultProgressInterval is the default number of files
+// after which the progress is logged at info level.
+const defaultProgressInterval = 100
+
+// ErrNoFiles is returned when there are no Go files to converge
+// in a directory, only if WithAllowEmpty(false) is set, since
+// empty directories are allowed by default. ConvergeToPackage
+// always returns it, since there is no package to return.
+var ErrNoFiles = errors.New("no Go files found")
+
+// ErrTooManyFiles is returned when there are more files to
+// converge than the limit set with WithMaxFiles.
+var ErrTooManyFiles = errors.New("too many files")
+
+// ErrInitCollision is returned when more than one init function
+// is converged and WithInitCollisionFatal is set.
+var ErrInitCollision = errors.New("multiple init functions")
+
+// debugLogger represents a logger that logs debug messages
+// and the occasional info message or warning.
 type debugLogger interface {
 	// Debugf logs a formatted debug message.
 	Debugf(format string, v ...any)
@@ -25,6 +59,12 @@ type debugLogger interface {
 	// Debug logs a debug message.
 	Debug(v ...any)
 
+	// Infof logs a formatted info message.
+	Infof(format string, v ...any)
+
+	// Warnf logs a formatted warning message.
+	Warnf(format string, v ...any)
+
 	// WithName returns a new logger with the given name.
 	WithName(name string) olog.LevelLogger
 }
@@ -39,9 +79,177 @@ type GoFileConverger struct {
 	// process all files in the given directory.
 	workers int
 
+	// concurrencyLimit is the maximum amount of files read
+	// at the same time, where zero means no limit besides
+	// the amount of workers.
+	concurrencyLimit int
+
 	// exclude is a map of regular expressions
 	// to apply to file names for exclusion.
-	exclude map[string]regexp.Regexp
+	exclude map[string]*regexp.Regexp
+
+	// excludeDirs is a map of regular expressions to apply
+	// to subdirectory names to exclude them from walking.
+	excludeDirs map[string]*regexp.Regexp
+
+	// allowEmpty writes nothing and returns no error
+	// when there are no Go files to converge, instead
+	// of returning ErrNoFiles.
+	allowEmpty bool
+
+	// outputPkgName is the package name of the merged
+	// output, if it should differ from the input files.
+	outputPkgName string
+
+	// detectInits warns when more than one init
+	// function is converged into the output.
+	detectInits bool
+
+	// initsFatal fails converging instead of warning
+	// when more than one init function is converged.
+	initsFatal bool
+
+	// pkgSet is the set of package names to include.
+	// If empty, files from all packages are included.
+	pkgSet map[string]struct{}
+
+	// excludeTests sets whether to exclude "_test.go" files.
+	// If nil, they're excluded unless package names to
+	// include are set.
+	excludeTests *bool
+
+	// onProgress is called each time a file
+	// has been processed, if it is set.
+	onProgress ProgressFunc
+
+	// scanBufferSize is the maximum length
+	// of a line of a file in bytes.
+	scanBufferSize int
+
+	// progressInterval is the number of files after which
+	// progress is logged at info level; 0 disables it.
+	progressInterval int
+
+	// recursive walks the subdirectories of the
+	// given directory as well as the directory itself.
+	recursive bool
+
+	// maxDepth limits how many levels of subdirectories
+	// are walked when recursive; negative is unlimited.
+	maxDepth int
+
+	// followSymlinks follows symbolic links to
+	// directories when walking subdirectories.
+	followSymlinks bool
+
+	// minFileSize is the minimum size of
+	// files to converge, in bytes.
+	minFileSize int64
+
+	// maxFileSize is the maximum size of files to
+	// converge in bytes, where zero means no maximum.
+	maxFileSize int64
+
+	// maxFiles is the maximum amount of files to
+	// converge, where zero means no maximum.
+	maxFiles int
+
+	// srcFiles are the exact files to converge instead
+	// of the files in the directory, if there are any.
+	srcFiles []string
+
+	// workerTimeout limits how long processing a single
+	// file may take, where zero means no limit.
+	workerTimeout time.Duration
+
+	// cache caches the processed files between runs,
+	// if enabled; see WithMtimeCache.
+	cache *fileCache
+
+	// skipTimedOut skips the files that time out
+	// instead of failing once all are processed.
+	skipTimedOut bool
+
+	// importOrder are the path prefixes of the
+	// import groups of the output, in order.
+	importOrder []string
+
+	// modulePath is the path of the Go module the
+	// converged files belong to, if it is known.
+	modulePath string
+
+	// fileOrder maps the base names of files to merge
+	// first to their position in the merged output.
+	fileOrder map[string]int
+
+	// fileAttribution precedes the code of each merged
+	// file with a comment naming its source file.
+	fileAttribution bool
+
+	// separator is the comment template to precede the code
+	// of each merged file with, where "%s" is replaced with
+	// the path of the file. Empty means no separator.
+	separator string
+
+	// dedup removes top-level declarations that
+	// duplicate earlier ones from the output.
+	dedup bool
+
+	// sortDecls sorts the top-level declarations
+	// of the output alphabetically by name.
+	sortDecls bool
+
+	// stripComments removes all comments
+	// from the converged output.
+	stripComments bool
+
+	// stripDocComments removes the doc comments of exported
+	// declarations from the converged output.
+	stripDocComments bool
+
+	// exportedPrefix is prepended to the names of all
+	// exported top-level declarations of the output.
+	exportedPrefix string
+
+	// outputHeader is prepended verbatim to
+	// the output before it is formatted.
+	outputHeader string
+
+	// outputSuffix is Go code appended to the
+	// merged code before it is formatted.
+	outputSuffix string
+
+	// exportedSuffix is appended to the names of all
+	// exported top-level declarations of the output.
+	exportedSuffix string
+
+	// noFormat disables formatting the converged
+	// output with go/format.
+	noFormat bool
+
+	// stream writes the merged code straight to the
+	// output when it is not formatted or transformed.
+	stream bool
+
+	// outputMode determines which parts of
+	// the converged file are written.
+	outputMode OutputMode
+
+	// buildTag is the build constraint expression
+	// to add to the converged output, if any.
+	buildTag string
+
+	// formatter formats the converged output instead
+	// of go/format, if it is set.
+	formatter FormatFunc
+
+	// preProcess transforms the contents of each
+	// file before it is processed, if it is set.
+	preProcess PreProcessFunc
+
+	// postProcess transforms the converged output
+	// before it is written, if it is set.
+	postProcess PostProcessFunc
 
 	// lg is the logger to use for logging.
 	lg debugLogger
@@ -54,10 +262,6 @@ type GoFileConverger struct {
 
 	// resCh is the channel that delivers processed files.
 	resCh chan *goFile
-
-	// errCh is the channel that concurrent functions
-	// can send errors to for
-	errCh chan error
 }
 
 // NewGoFileConverger creates a new GoFileConverger with sensible defaults,
@@ -70,12 +274,18 @@ func NewGoFileConverger(opts ...Option) *GoFileConverger {
 	}
 
 	gfc := GoFileConverger{
-		workers: workers,
-		exclude: make(map[string]regexp.Regexp),
-		fpCh:    make(chan string, workers),
-		resCh:   make(chan *goFile),
-		errCh:   make(chan error),
-		lg:      olog.NewNoopLogger(),
+		workers:          workers,
+		exclude:          make(map[string]*regexp.Regexp),
+		excludeDirs:      make(map[string]*regexp.Regexp),
+		pkgSet:           make(map[string]struct{}),
+		maxDepth:         -1,
+		allowEmpty:       true,
+		outputMode:       OutputModeFull,
+		progressInterval: defaultProgressInterval,
+		scanBufferSize:   defaultScanBufferSize,
+		fpCh:             make(chan string, workers),
+		resCh:            make(chan *goFile),
+		lg:               olog.NewNoopLogger(),
 	}
 
 	for _, opt := range opts {
@@ -99,7 +309,7 @@ func WithLogger(lg debugLogger) Option {
 // expressions that define which files should be excluded from
 // the merging process. This is useful for excluding test files
 // or specific files in a directory.
-func WithExcludes(excludes []regexp.Regexp) Option {
+func WithExcludes(excludes []*regexp.Regexp) Option {
 	return func(gfc *GoFileConverger) {
 		for _, e := range excludes {
 			gfc.exclude[e.String()] = e
@@ -107,6 +317,436 @@ func WithExcludes(excludes []regexp.Regexp) Option {
 	}
 }
 
+// WithExcludeDirs sets the regular expressions for the names of
+// subdirectories to skip entirely when walking recursively, e.g.
+// vendor or testdata. The root directory itself is never skipped.
+func WithExcludeDirs(excludes []*regexp.Regexp) Option {
+	return func(gfc *GoFileConverger) {
+		for _, e := range excludes {
+			gfc.excludeDirs[e.String()] = e
+		}
+	}
+}
+
+// WithPackages allows the caller to specify a list of package
+// names to include in the merging process. Files declaring any
+// other package are skipped. This is useful for directories that
+// contain files from more than one package (e.g. external tests).
+func WithPackages(pkgs []string) Option {
+	return func(gfc *GoFileConverger) {
+		for _, p := range pkgs {
+			gfc.pkgSet[p] = struct{}{}
+		}
+	}
+}
+
+// WithExcludeTestFiles sets whether to exclude "_test.go" files, which
+// is the default. Including them is useful e.g. to merge the tests of
+// a package into a single file. With WithPackages, the test files of
+// the given packages are included by default, since they were asked
+// for, unless they're explicitly excluded.
+func WithExcludeTestFiles(exclude bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.excludeTests = &exclude
+	}
+}
+
+// WithAllowEmpty sets whether converging a directory without any
+// Go files succeeds without writing anything, which is the default,
+// or fails with ErrNoFiles.
+func WithAllowEmpty(allow bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.allowEmpty = allow
+	}
+}
+
+// WithOutputPackageName sets the package name of the merged output.
+// Unlike WithPackages, it doesn't filter which files are converged.
+// Files of different packages may be converged into the named one, in
+// which case references between them, e.g. "foo.Thing" in a file of
+// package bar, are unqualified and the imports of the merged packages
+// are removed, since they are now declared in the same package.
+func WithOutputPackageName(name string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.outputPkgName = name
+	}
+}
+
+// WithDetectInitCollisions sets whether to warn when the converged files
+// declare more than one init function between them. That is valid Go,
+// but when merging files from different layers it is often a mistake,
+// e.g. two init functions that both modify the same global state.
+func WithDetectInitCollisions(detect bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.detectInits = detect
+	}
+}
+
+// WithInitCollisionFatal is like WithDetectInitCollisions, but fails with
+// ErrInitCollision instead of warning when there is more than one init
+// function.
+func WithInitCollisionFatal(fatal bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.detectInits = fatal || gfc.detectInits
+		gfc.initsFatal = fatal
+	}
+}
+
+// WithRecursive converges the Go files in all subdirectories of the
+// given directory as well. By default, only the files directly in
+// the given directory are converged.
+func WithRecursive(recursive bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.recursive = recursive
+	}
+}
+
+// WithMaxDepth limits how many levels of subdirectories are walked
+// when converging recursively. Depth 0 only includes the given
+// directory itself, and -1 (the default) walks all subdirectories.
+func WithMaxDepth(n int) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.maxDepth = n
+	}
+}
+
+// WithFollowSymlinks follows symbolic links to directories when
+// converging recursively, which is useful when shared Go files are
+// symlinked into a package. Symbolic links that form a cycle are
+// only walked once.
+func WithFollowSymlinks(follow bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.followSymlinks = follow
+	}
+}
+
+// WithNoFormat disables formatting the converged output with go/format.
+// This is considerably faster for very large outputs, at the cost of
+// the output not being pretty-printed.
+func WithNoFormat(noFormat bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.noFormat = noFormat
+	}
+}
+
+// WithStreaming sets whether to write the merged code straight to the
+// output, instead of building the full source in memory first, which
+// halves the peak memory use for very large outputs. It only applies
+// together with WithNoFormat and when no other option transforms the
+// output. Since the output is never held in memory, it is not checked
+// to be valid Go as it otherwise is without formatting.
+func WithStreaming(stream bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.stream = stream
+	}
+}
+
+// WithOutputMode sets which parts of the converged file are written,
+// e.g. OutputModeDeclarations to embed the code into another file.
+// The file is formatted in full before anything is omitted.
+func WithOutputMode(mode OutputMode) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.outputMode = mode
+	}
+}
+
+// WithBuildTag adds a //go:build constraint with the given expression,
+// e.g. "linux && amd64", to the top of the converged output. The
+// expression is validated before any files are processed.
+func WithBuildTag(expr string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.buildTag = expr
+	}
+}
+
+// FormatFunc formats the given Go source code,
+// returning the formatted source code.
+type FormatFunc func(src []byte) ([]byte, error)
+
+// WithCustomFormatter sets the function used to format the converged
+// output instead of go/format, e.g. to run gofumpt or goimports. The
+// formatter is not used if formatting has been disabled.
+func WithCustomFormatter(fn FormatFunc) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.formatter = fn
+	}
+}
+
+// PreProcessFunc transforms the contents of the Go
+// source file at the given path before it is processed.
+type PreProcessFunc func(path string, src []byte) ([]byte, error)
+
+// WithPreProcessHook sets a function that transforms the raw contents
+// of each file before it is processed, e.g. to substitute template
+// variables or scrub secrets. It is called concurrently by the workers.
+func WithPreProcessHook(fn PreProcessFunc) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.preProcess = fn
+	}
+}
+
+// PostProcessFunc transforms the converged Go source code.
+type PostProcessFunc func(src []byte) ([]byte, error)
+
+// WithPostProcessHook sets a function that transforms the final
+// converged output right before it is written, e.g. to inject extra
+// declarations. The output is not formatted again after the hook.
+func WithPostProcessHook(fn PostProcessFunc) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.postProcess = fn
+	}
+}
+
+// WithProgressCallback sets a function that is called each
+// time a file has been processed, which allows callers to
+// report the progress of long-running converge operations.
+func WithProgressCallback(fn ProgressFunc) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.onProgress = fn
+	}
+}
+
+// WithProgressInterval sets the number of files after which the
+// progress is logged at info level, so converging a large directory
+// isn't silent until it's done. Values below 1 disable it.
+func WithProgressInterval(n int) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.progressInterval = max(n, 0)
+	}
+}
+
+// WithScanBufferSize sets the maximum length of a line of a file in
+// bytes, which defaults to 1MB. Files with longer lines, e.g. large
+// generated data tables, fail to converge. Values below 1 are ignored.
+func WithScanBufferSize(size int) Option {
+	return func(gfc *GoFileConverger) {
+		if size > 0 {
+			gfc.scanBufferSize = size
+		}
+	}
+}
+
+// WithMinFileSize skips files smaller than the given
+// number of bytes, e.g. empty stub files.
+func WithMinFileSize(size int64) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.minFileSize = size
+	}
+}
+
+// WithMaxFileSize skips files larger than the given number of bytes,
+// e.g. huge generated files. Zero means there is no maximum.
+func WithMaxFileSize(size int64) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.maxFileSize = size
+	}
+}
+
+// WithMaxFiles sets the maximum amount of files to converge, as a safety
+// limit against accidentally converging a huge tree, e.g. by recursively
+// converging the wrong directory. Walking stops as soon as the limit is
+// exceeded and ErrTooManyFiles is returned. Zero means no limit.
+func WithMaxFiles(n int) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.maxFiles = n
+	}
+}
+
+// WithSrcFile converges exactly the given files instead of walking a
+// directory, e.g. a file list from a build system. The directory passed
+// to ConvergeFiles is then ignored, apart from being used for relative
+// paths in file attribution comments. No filters are applied to the
+// files, but they are still merged in the configured file order.
+func WithSrcFile(paths []string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.srcFiles = paths
+	}
+}
+
+// WithWorkerTimeout limits how long a worker may spend processing a
+// single file, e.g. one on a slow network filesystem. A file that times
+// out doesn't stop the other files from being processed, but converging
+// still fails once they are done. Zero or a negative duration means no
+// limit, which is the default.
+func WithWorkerTimeout(d time.Duration) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.workerTimeout = d
+	}
+}
+
+// WithSkipTimedOutFiles skips the files that take longer to process
+// than the worker timeout with a warning, so they are left out of the
+// output instead of failing the converge. It has no effect without a
+// worker timeout.
+func WithSkipTimedOutFiles(skip bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.skipTimedOut = skip
+	}
+}
+
+// WithMtimeCache caches each processed file in memory, so converging
+// again after Reset, e.g. whenever a watched directory changes, only
+// reads and processes the files whose modification time or size has
+// changed since. Since the cached results are those of the pre-process
+// hook, if any, the hook is only run again for changed files too.
+func WithMtimeCache(enabled bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.cache = nil
+		if enabled {
+			gfc.cache = newFileCache()
+		}
+	}
+}
+
+// WithFileOrder merges the files with the given base names first, in
+// the given order, e.g. to always start the output with doc.go. All
+// other files are merged after them in lexicographic order.
+func WithFileOrder(names []string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.fileOrder = make(map[string]int, len(names))
+		for _, name := range names {
+			if _, ok := gfc.fileOrder[name]; !ok {
+				gfc.fileOrder[name] = len(gfc.fileOrder)
+			}
+		}
+	}
+}
+
+// WithImportOrder sets the order of the import groups of the output,
+// as a list of import path prefixes, e.g. "github.com/myorg/". Each
+// import goes in the group of the first prefix it matches, and imports
+// matching no prefix go in a last group. The groups are separated by
+// blank lines and sorted alphabetically, so gofmt keeps them as they
+// are. Without an import order all imports are in a single group.
+func WithImportOrder(groups []string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.importOrder = groups
+	}
+}
+
+// WithModulePath sets the path of the Go module the converged files
+// belong to, e.g. "github.com/myorg/mylib". Imports of packages within
+// the module may refer to declarations that are now merged into the
+// output, or to the output's own package, so a warning is logged for
+// each of them.
+func WithModulePath(path string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.modulePath = strings.TrimSuffix(path, "/")
+	}
+}
+
+// WithFileAttribution precedes the code of each merged file with a
+// "// Source: <path>" comment, where the path is relative to the
+// converged directory. This makes it easy to trace declarations in
+// the output back to the file they came from.
+func WithFileAttribution(attribution bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.fileAttribution = attribution
+	}
+}
+
+// WithSeparator precedes the code of each merged file with the given
+// comment, where every "%s" is replaced with the path of the file
+// relative to the converged directory, e.g. "// --- %s ---". If the
+// separator isn't a comment already, it is turned into a line comment.
+// The separator must be a single line.
+func WithSeparator(separator string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.separator = separator
+	}
+}
+
+// WithOutputHeader prepends the given string verbatim to the output,
+// before it is formatted, e.g. a license identifier or "//nolint:all".
+// Unlike the other transformations, the header is added last, so it is
+// never e.g. stripped with the comments. It must only contain comments,
+// since it goes before any build constraint and the package clause, so
+// it is dropped with them in the snippet and declarations output modes.
+func WithOutputHeader(header string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.outputHeader = header
+	}
+}
+
+// WithOutputSuffix appends the given Go code, e.g. "func init() {
+// registerAll() }", to the merged code of every converged directory,
+// before it is formatted. The code may only use packages that the
+// merged files import. Converging fails before any files are processed
+// if the code isn't made up of valid top-level declarations.
+func WithOutputSuffix(code string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.outputSuffix = strings.TrimSpace(code)
+	}
+}
+
+// WithDeduplication removes top-level declarations from the converged
+// output whose names were already declared by an earlier declaration,
+// e.g. a helper function copied into several files. The first occurrence
+// is kept. Without it, duplicate declarations make the output invalid.
+func WithDeduplication(dedup bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.dedup = dedup
+	}
+}
+
+// WithSortDeclarations sorts all top-level declarations of the
+// converged output alphabetically by name, so the output doesn't
+// depend on the order files were processed in. Init functions and
+// the main function are sorted to the end.
+func WithSortDeclarations(sortDecls bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.sortDecls = sortDecls
+	}
+}
+
+// WithStripComments removes all comments from the converged output,
+// which is useful for embedding the code in documentation or producing
+// minified vendor files. Compiler directives such as //go:embed are kept.
+func WithStripComments(strip bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.stripComments = strip
+	}
+}
+
+// WithStripDocComments removes only the doc comments of exported
+// top-level declarations from the converged output.
+func WithStripDocComments(strip bool) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.stripDocComments = strip
+	}
+}
+
+// WithExportedPrefix prepends the given prefix to the names of all
+// exported top-level functions, types, variables and constants of the
+// converged output, and to every reference to them, e.g. to vendor a
+// copy of a package next to the original. Methods are not renamed. The
+// prefix must start with an upper case letter to keep names exported.
+func WithExportedPrefix(prefix string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.exportedPrefix = prefix
+	}
+}
+
+// WithExportedSuffix appends the given suffix to the names of all
+// exported top-level functions, types, variables and constants of the
+// converged output, and to every reference to them, e.g. "V2" renames
+// FooFunc to FooFuncV2. Methods are not renamed.
+func WithExportedSuffix(suffix string) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.exportedSuffix = suffix
+	}
+}
+
+// WithConcurrencyLimit limits how many files are read at the same
+// time, independent of the amount of workers. This keeps slow disks
+// or network filesystems from being thrashed by concurrent reads,
+// while the workers can still parse files in parallel.
+func WithConcurrencyLimit(n int) Option {
+	return func(gfc *GoFileConverger) {
+		gfc.concurrencyLimit = n
+	}
+}
+
 // WithMaxWorkers sets the maximum amount of workers to use and
 // adjusts the file producer channel accordingly.
 func WithMaxWorkers(maxWorkers int) Option {
@@ -116,87 +756,551 @@ func WithMaxWorkers(maxWorkers int) Option {
 	}
 }
 
+// Reset prepares the converger to be used again. A converger can
+// only run once, since converging closes the channels its workers
+// communicate over, so Reset replaces them with fresh ones. It must
+// not be called while a converge operation is running.
+func (c *GoFileConverger) Reset() {
+	c.fpCh = make(chan string, c.workers)
+	c.resCh = make(chan *goFile)
+}
+
+// Result describes a completed converge operation.
+type Result struct {
+	// Files is the number of files that were merged.
+	Files int
+
+	// Skipped are the paths of the Go files that were
+	// found, but excluded from merging by the filters
+	// (exclude patterns, file sizes and packages).
+	Skipped []string
+
+	// Duration is how long the operation took.
+	Duration time.Duration
+}
+
 // ConvergeFiles converges all Go files in the given directory and
 // package into one and writes the result to the given output.
 func (c *GoFileConverger) ConvergeFiles(ctx context.Context, dir string, w io.Writer) error {
-	var (
-		producerWG sync.WaitGroup
-		consumerWG sync.WaitGroup
-	)
+	_, err := c.convergeFiles(ctx, dir, w)
+	return err
+}
 
-	lg := c.lg.WithName("ConvergeFiles")
+// ConvergeDir converges all Go files in the given directory like
+// ConvergeFiles, and writes the result to the given output file,
+// which is created or truncated, or to os.Stdout if it is empty.
+// The output is converged before the file is created, so a failed
+// converge leaves an existing file untouched.
+func (c *GoFileConverger) ConvergeDir(ctx context.Context, dir, outFile string) (err error) {
+	if outFile == "" {
+		return c.ConvergeFiles(ctx, dir, os.Stdout)
+	}
 
-	// Start consumer worker pool
-	lg.Debugf("Starting %d consumer workers", c.workers)
-	for range c.workers {
-		consumerWG.Add(1)
-		go func() {
-			defer consumerWG.Done()
-			consumer := newFileConsumer(c.fpCh, c.resCh, c.errCh)
-			consumer.consume(ctx)
-		}()
+	src, _, err := c.ConvergeFilesWithResult(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(outFile)
+	if err != nil {
+		return fmt.Errorf("failed to create output file %s: %w", outFile, err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close output file %s: %w", outFile, cerr)
+		}
+	}()
+
+	if _, err = f.Write(src); err != nil {
+		return fmt.Errorf("failed to write output file %s: %w", outFile, err)
+	}
+
+	return nil
+}
+
+// ConvergeFilesWithResult is like ConvergeFiles, but returns the
+// converged output along with a Result describing the operation,
+// instead of writing the output to a writer.
+func (c *GoFileConverger) ConvergeFilesWithResult(ctx context.Context, dir string) ([]byte, Result, error) {
+	var buf bytes.Buffer
+	res, err := c.convergeFiles(ctx, dir, &buf)
+	if err != nil {
+		return nil, Result{}, err
 	}
+	return buf.Bytes(), res, nil
+}
+
+// convergeFiles converges all Go files in the given directory into
+// one, writes the result to the given output and describes it.
+func (c *GoFileConverger) convergeFiles(ctx context.Context, dir string, w io.Writer) (Result, error) {
+	start := time.Now()
+
+	// Check the build tag and output mode up front, so
+	// invalid ones fail before any files are processed.
+	if _, err := c.buildConstraint(); err != nil {
+		return Result{}, err
+	}
+	if err := c.outputMode.validate(); err != nil {
+		return Result{}, err
+	}
+
+	outFile, err := c.converge(ctx, dir)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{
+		Files:   outFile.files,
+		Skipped: outFile.skipped,
+	}
+
+	// Without a package name there were no Go files to
+	// converge, so there is nothing to write either.
+	if outFile.pkgName == "" {
+		if !c.allowEmpty {
+			return Result{}, fmt.Errorf("%w in directory: %s", ErrNoFiles, dir)
+		}
+		c.lg.Infof("No Go files found in directory: %s", dir)
+		res.Duration = time.Since(start)
+		return res, nil
+	}
+
+	if c.canStream() {
+		c.lg.Debugf("Streaming unformatted output")
+		if err = outFile.writeCode(w); err != nil {
+			return Result{}, fmt.Errorf("failed to write output: %w", err)
+		}
+		res.Duration = time.Since(start)
+		return res, nil
+	}
+
+	// Build and format the output.
+	outBytes, err := c.render(outFile)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to format code: %w", err)
+	}
+	if outBytes, err = trimOutput(outBytes, c.outputMode); err != nil {
+		return Result{}, fmt.Errorf("failed to trim output: %w", err)
+	}
+	if c.postProcess != nil {
+		if outBytes, err = c.postProcess(outBytes); err != nil {
+			return Result{}, fmt.Errorf("failed to run post-process hook: %w", err)
+		}
+	}
+
+	// Write the output.
+	_, err = w.Write(outBytes)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to write output: %w", err)
+	}
+	res.Duration = time.Since(start)
+
+	return res, nil
+}
+
+// DryRun runs the full converge pipeline on the given directory, but
+// returns the converged output instead of writing it anywhere. This is
+// convenient for using the converger as a library, e.g. to validate
+// the output in-process.
+func (c *GoFileConverger) DryRun(ctx context.Context, dir string) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := c.ConvergeFiles(ctx, dir, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// converge processes all Go files in the given
+// directory and merges them into a single goFile.
+//
+// The producer and consumers run in an errgroup, so the first
+// error returned by any of them cancels all the others, since this
+// is an all or nothing operation (can't *half* converge files).
+func (c *GoFileConverger) converge(ctx context.Context, dir string) (*goFile, error) {
+	// Check the output suffix up front, so an
+	// invalid one fails before any files are processed.
+	if err := c.validateOutputSuffix(); err != nil {
+		return nil, err
+	}
+
+	lg := c.lg.WithName("converge")
+	prog := newProgress(c.progressFunc(lg))
+	g, gctx := errgroup.WithContext(ctx)
 
 	// Setup and start producer
 	lg.Debugf("Producing files in directory: %s", dir)
-	producerWG.Add(1)
-	go func() {
-		defer producerWG.Done()
+	producer := newFileProducer(c.lg, c.walkOptions(), prog, c.fpCh)
+	g.Go(func() error {
 		defer close(c.fpCh) // Close only after producer is done
 
-		producer := newFileProducer(c.lg, c.exclude, c.fpCh, c.errCh)
-
 		c.lg.Debugf("Starting file producer for directory: %s", dir)
-		producer.produce(dir)
-	}()
+		return producer.produce(gctx, dir)
+	})
+
+	// Start consumer worker pool
+	lg.Debugf("Starting %d consumer workers", c.workers)
+	var sem *semaphore.Weighted
+	if c.concurrencyLimit > 0 {
+		sem = semaphore.NewWeighted(int64(c.concurrencyLimit))
+	}
+	proc := newProcessFunc(sem, c.preProcess, c.scanBufferSize)
+	if c.cache != nil {
+		proc = c.cache.wrap(proc)
+	}
+	for range c.workers {
+		g.Go(func() error {
+			consumer := newFileConsumer(c.lg, c.fpCh, c.resCh, prog, proc, c.workerTimeout, c.skipTimedOut)
+			return consumer.consume(gctx)
+		})
+	}
 
 	// Wait for the producer and consumers
-	// to finish before closing channels
+	// to finish before closing the results.
 	go func() {
-		producerWG.Wait()
-		consumerWG.Wait()
+		_ = g.Wait()
 		close(c.resCh)
-		close(c.errCh)
 	}()
 
-	// Build the Go file from the results.
-	outFile, err := c.buildFile(ctx)
+	// Build the Go file from the results. All results
+	// must be drained, even if an error occurred.
+	outFile := c.buildFile(dir)
+	if err := g.Wait(); err != nil {
+		return nil, fmt.Errorf("failed to build file: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to build file: %w", err)
+	}
+	outFile.skipped = producer.skipped
+	if c.outputSuffix != "" && outFile.pkgName != "" {
+		outFile.appendCode("\n" + c.outputSuffix)
+	}
+
+	if err := c.checkInits(outFile); err != nil {
+		return nil, err
+	}
+	c.checkModuleImports(outFile)
+
+	return outFile, nil
+}
+
+// checkInits warns, or fails if that is configured, when the given
+// goFile has more than one init function and detection is enabled.
+func (c *GoFileConverger) checkInits(gf *goFile) error {
+	if !c.detectInits || len(gf.inits) < 2 {
+		return nil
+	}
+
+	paths := strings.Join(gf.inits, ", ")
+	if c.initsFatal {
+		return fmt.Errorf("%w declared in %s", ErrInitCollision, paths)
+	}
+	c.lg.Warnf("Multiple init functions declared in %s", paths)
+
+	return nil
+}
+
+// progressFunc returns the function progress is reported to. It logs
+// the progress every progressInterval files, in addition to calling
+// the progress callback, if either is set.
+func (c *GoFileConverger) progressFunc(lg debugLogger) ProgressFunc {
+	if c.progressInterval == 0 {
+		return c.onProgress
+	}
+
+	return func(path string, done, total int) {
+		if done%c.progressInterval == 0 {
+			lg.Infof("Processed %d/%d files", done, total)
+		}
+		if c.onProgress != nil {
+			c.onProgress(path, done, total)
+		}
+	}
+}
+
+// checkModuleImports warns about each import of the given goFile of a
+// package within the module, if the module path is set. The package
+// is named by its path relative to the module, e.g. "pkg" or ".".
+func (c *GoFileConverger) checkModuleImports(gf *goFile) {
+	if c.modulePath == "" {
+		return
+	}
+
+	for _, imp := range slices.Sorted(maps.Keys(gf.imports)) {
+		rel, ok := moduleRelPath(c.modulePath, importPath(imp))
+		if ok {
+			c.lg.Warnf("Merged file imports package %q of its own module %s", rel, c.modulePath)
+		}
+	}
+}
+
+// prependHeader prepends the given header to the given source on a
+// line of its own, and checks the source still has a valid package
+// clause, i.e. the header doesn't contain anything but comments.
+func prependHeader(src []byte, header string) ([]byte, error) {
+	if !strings.HasSuffix(header, "\n") {
+		header += "\n"
+	}
+	src = append([]byte(header), src...)
+
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly); err != nil {
+		return nil, fmt.Errorf("invalid output header %q: %w", header, err)
+	}
+
+	return src, nil
+}
+
+// canStream returns true if streaming is enabled and the merged code
+// can be written as is, since it is neither formatted nor transformed.
+func (c *GoFileConverger) canStream() bool {
+	return c.stream && c.noFormat &&
+		c.buildTag == "" && c.outputHeader == "" &&
+		c.outputPkgName == "" &&
+		!c.dedup && !c.sortDecls &&
+		!c.stripComments && !c.stripDocComments &&
+		c.exportedPrefix == "" && c.exportedSuffix == "" &&
+		c.outputMode == OutputModeFull &&
+		c.postProcess == nil
+}
+
+// validateOutputSuffix returns an error if the configured output
+// suffix isn't made up of valid top-level declarations.
+func (c *GoFileConverger) validateOutputSuffix() error {
+	if c.outputSuffix == "" {
+		return nil
+	}
+
+	src := "package p\n\n" + c.outputSuffix
+	if _, err := parser.ParseFile(token.NewFileSet(), "", src, parser.DeclarationErrors); err != nil {
+		return fmt.Errorf("invalid output suffix: %w", err)
+	}
+
+	return nil
+}
+
+// buildConstraint returns the //go:build line for the configured
+// build tag, or an empty string if no build tag is configured.
+func (c *GoFileConverger) buildConstraint() (string, error) {
+	if c.buildTag == "" {
+		return "", nil
+	}
+
+	expr, err := constraint.Parse("//go:build " + c.buildTag)
 	if err != nil {
-		return fmt.Errorf("failed to buildFile file converger: %w", err)
+		return "", fmt.Errorf("invalid build tag %q: %w", c.buildTag, err)
 	}
 
-	// Build and format the output.
-	outBytes, err := outFile.FormatCode()
+	return "//go:build " + expr.String(), nil
+}
+
+// render returns the source code for the given goFile after
+// applying any configured transformations. The result is
+// formatted unless formatting has been disabled.
+func (c *GoFileConverger) render(gf *goFile) ([]byte, error) {
+	buildLine, err := c.buildConstraint()
 	if err != nil {
-		return fmt.Errorf("failed to format code: %w", err)
+		return nil, err
 	}
 
-	// Write the output.
-	_, err = w.Write(outBytes)
+	src := gf.source()
+	if buildLine != "" {
+		src = append([]byte(buildLine+"\n\n"), src...)
+	}
+	if c.outputPkgName != "" {
+		if src, err = renamePackage(src, c.outputPkgName, gf.pkgNames); err != nil {
+			return nil, fmt.Errorf("failed to rename package: %w", err)
+		}
+	}
+	if c.dedup {
+		var removed []string
+		if src, removed, err = dedupDeclarations(src); err != nil {
+			return nil, fmt.Errorf("failed to remove duplicate declarations: %w", err)
+		}
+		for _, name := range removed {
+			c.lg.Debugf("Removed duplicate declaration: %s", name)
+		}
+	}
+	if c.sortDecls {
+		if src, err = sortDeclarations(src); err != nil {
+			return nil, fmt.Errorf("failed to sort declarations: %w", err)
+		}
+	}
+
+	if c.stripComments || c.stripDocComments {
+		if src, err = stripComments(src, !c.stripComments); err != nil {
+			return nil, fmt.Errorf("failed to strip comments: %w", err)
+		}
+	}
+
+	if c.exportedPrefix != "" || c.exportedSuffix != "" {
+		if src, err = renameExported(src, c.exportedPrefix, c.exportedSuffix); err != nil {
+			return nil, fmt.Errorf("failed to rename exported declarations: %w", err)
+		}
+	}
+
+	if c.outputHeader != "" {
+		if src, err = prependHeader(src, c.outputHeader); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.noFormat {
+		// Without go/format nothing guarantees the output is valid
+		// Go, so check it parses and warn the user if it doesn't.
+		if _, err = parser.ParseFile(token.NewFileSet(), "", src, parser.AllErrors); err != nil {
+			c.lg.Warnf("Unformatted output is not valid Go source: %v", err)
+		}
+		return src, nil
+	}
+
+	if c.formatter != nil {
+		b, err := c.formatter(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to format code with custom formatter: %w", err)
+		}
+		return b, nil
+	}
+
+	// Use go/format to format the code in standard gofmt style.
+	b, err := format.Source(src)
 	if err != nil {
-		return fmt.Errorf("failed to write output: %w", err)
+		return nil, fmt.Errorf("failed to format code: %w", err)
 	}
 
-	return nil
+	return b, nil
+}
+
+// ListFiles returns the lexicographically sorted list of files in the
+// given directory that would be converged by ConvergeFiles. The files
+// aren't processed, which makes this a cheap way to preview what a full
+// converge operation would process, though with WithPackages the package
+// clause of each Go file is parsed to check its package.
+func (c *GoFileConverger) ListFiles(ctx context.Context, dir string) ([]string, error) {
+	lg := c.lg.WithName("ListFiles")
+	lg.Debugf("Listing files in directory: %s", dir)
+
+	producer := newFileProducer(c.lg, c.walkOptions(), nil, nil)
+	files, err := producer.walkDir(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error walking directory: %w", err)
+	}
+
+	slices.SortFunc(files, c.comparePaths)
+
+	return files, nil
 }
 
-// buildFile handles running the converger and returning the result or an error.
-func (c *GoFileConverger) buildFile(ctx context.Context) (*goFile, error) {
+// Imports returns the sorted, deduplicated import paths of all files
+// in the given directory that would be converged by ConvergeFiles.
+// Aliases are dropped, so a path imported under different names is
+// only returned once.
+func (c *GoFileConverger) Imports(ctx context.Context, dir string) ([]string, error) {
+	gf, err := c.converge(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(map[string]struct{}, len(gf.imports))
+	for imp := range gf.imports {
+		if path := importPath(imp); path != "" {
+			set[path] = struct{}{}
+		}
+	}
+
+	return slices.Sorted(maps.Keys(set)), nil
+}
+
+// comparePaths compares file paths for the order files are merged in.
+// Files listed with WithFileOrder come first, in the given order, and
+// all other files follow in lexicographic order.
+func (c *GoFileConverger) comparePaths(a, b string) int {
+	rank := func(path string) int {
+		if i, ok := c.fileOrder[filepath.Base(path)]; ok {
+			return i
+		}
+		return len(c.fileOrder)
+	}
+
+	return cmp.Or(
+		cmp.Compare(rank(a), rank(b)),
+		cmp.Compare(a, b),
+	)
+}
+
+// walkOptions returns the options for walking the directory
+// to find the files to converge.
+func (c *GoFileConverger) walkOptions() walkOptions {
+	// Without recursion, only the directory itself is walked.
+	var maxDepth int
+	if c.recursive {
+		maxDepth = c.maxDepth
+	}
+
+	// Test files of the packages to include are
+	// included, unless they're explicitly excluded.
+	excludeTests := len(c.pkgSet) == 0
+	if c.excludeTests != nil {
+		excludeTests = *c.excludeTests
+	}
+
+	return walkOptions{
+		excludes:       c.exclude,
+		excludeDirs:    c.excludeDirs,
+		pkgSet:         c.pkgSet,
+		excludeTests:   excludeTests,
+		maxDepth:       maxDepth,
+		followSymlinks: c.followSymlinks,
+		minSize:        c.minFileSize,
+		maxSize:        c.maxFileSize,
+		maxFiles:       c.maxFiles,
+		srcFiles:       c.srcFiles,
+	}
+}
+
+// buildFile merges all processed files into a single goFile.
+// It returns once the results channel has been closed. Files
+// arrive in whatever order the workers finish them, so they are
+// merged sorted by their source path to keep the output stable.
+func (c *GoFileConverger) buildFile(dir string) *goFile {
+	var files []*goFile
+	for f := range c.resCh {
+		files = append(files, f)
+	}
+	slices.SortFunc(files, func(a, b *goFile) int {
+		return c.comparePaths(a.srcPath, b.srcPath)
+	})
+
 	gf := newGoFile()
-	for {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		case err, ok := <-c.errCh:
-			if !ok {
-				return gf, nil
-			}
-			return nil, err
-		case f, ok := <-c.resCh:
-			if !ok {
-				return gf, nil
-			}
-			gf.merge(f)
+	gf.importOrder = c.importOrder
+	for _, f := range files {
+		gf.merge(f, c.fileComments(dir, f.srcPath)...)
+	}
+	return gf
+}
+
+// fileComments returns the comment lines to precede the code
+// of the given source file with in the merged output, based
+// on the separator and file attribution options.
+func (c *GoFileConverger) fileComments(dir, path string) []string {
+	if c.separator == "" && !c.fileAttribution {
+		return nil
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = filepath.Base(path)
+	}
+	rel = filepath.ToSlash(rel)
+
+	var comments []string
+	if c.separator != "" {
+		sep := strings.ReplaceAll(c.separator, "%s", rel)
+		if !strings.HasPrefix(sep, "//") && !strings.HasPrefix(sep, "/*") {
+			sep = "// " + sep
 		}
+		comments = append(comments, sep)
+	}
+	if c.fileAttribution {
+		comments = append(comments, "// Source: "+rel)
 	}
+
+	return comments
 }
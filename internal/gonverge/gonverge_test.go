@@ -1,16 +1,32 @@
 package gonverge_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/dannyhinshaw/converge/internal/gonverge"
+	"github.com/dannyhinshaw/converge/internal/olog"
 )
 
 func TestGoFileConverger_ConvergeFiles(t *testing.T) {
@@ -20,7 +36,8 @@ func TestGoFileConverger_ConvergeFiles(t *testing.T) {
 
 	tests := map[string]struct {
 		files    map[string]string
-		excludes []regexp.Regexp
+		excludes []*regexp.Regexp
+		packages []string
 		expected string
 		err      bool
 	}{
@@ -48,7 +65,7 @@ func TestGoFileConverger_ConvergeFiles(t *testing.T) {
 				"exclude.go": "package main\nfunc exclude() {}",
 			},
 			expected: "package main\n\nfunc func1() {}\nfunc func2() {}\n",
-			excludes: []regexp.Regexp{*excludeRe},
+			excludes: []*regexp.Regexp{excludeRe},
 		},
 		"MultipleFilesWithExclusionButNoFile": {
 			files: map[string]string{
@@ -56,7 +73,7 @@ func TestGoFileConverger_ConvergeFiles(t *testing.T) {
 				"file2.go": "package main\nfunc func2() {}",
 			},
 			expected: "package main\n\nfunc func1() {}\nfunc func2() {}\n",
-			excludes: []regexp.Regexp{*excludeRe},
+			excludes: []*regexp.Regexp{excludeRe},
 		},
 		"MultipleFilesWithNonGoFiles": {
 			files: map[string]string{
@@ -66,37 +83,2437 @@ func TestGoFileConverger_ConvergeFiles(t *testing.T) {
 			},
 			expected: "package main\n\nfunc func1() {}\nfunc func2() {}\n",
 		},
+		"MultipleFilesWithPackageFilter": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file2.go": "package maintest\nfunc func2() {}",
+				"file3.go": "package main\nfunc func3() {}",
+			},
+			expected: "package main\n\nfunc func1() {}\nfunc func3() {}\n",
+			packages: []string{"main"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := createTempDirWithFiles(t, tc.files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			// Use only one worker to make the test deterministic.
+			opts := []gonverge.Option{
+				gonverge.WithMaxWorkers(1),
+			}
+			if len(tc.excludes) > 0 {
+				opts = append(opts, gonverge.WithExcludes(tc.excludes))
+			}
+			if len(tc.packages) > 0 {
+				opts = append(opts, gonverge.WithPackages(tc.packages))
+			}
+			converger := gonverge.NewGoFileConverger(
+				opts...,
+			)
+
+			var output bytes.Buffer
+
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			a.False(tc.err && err == nil)
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_ImportForms(t *testing.T) {
+	tests := map[string]struct {
+		src      string
+		expected string
+	}{
+		"DotImport": {
+			src:      "package main\nimport . \"fmt\"\nfunc main() { Println() }",
+			expected: "package main\n\nimport . \"fmt\"\n\nfunc main() { Println() }\n",
+		},
+		"BlankImport": {
+			src:      "package main\nimport _ \"embed\"\nfunc main() {}",
+			expected: "package main\n\nimport _ \"embed\"\n\nfunc main() {}\n",
+		},
+		"AliasedImport": {
+			src:      "package main\nimport f \"fmt\"\nfunc main() { f.Println() }",
+			expected: "package main\n\nimport f \"fmt\"\n\nfunc main() { f.Println() }\n",
+		},
+		"ImportBlock": {
+			src: "package main\nimport (\n\t. \"fmt\"\n\t_ \"embed\"\n\ts \"strings\"\n)\n" +
+				"func main() { Println(s.ToUpper(\"\")) }",
+			expected: "package main\n\nimport (\n\t_ \"embed\"\n\t. \"fmt\"\n\ts \"strings\"\n)\n\n" +
+				"func main() { Println(s.ToUpper(\"\")) }\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file.go": tc.src,
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger()
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_ImportOrder(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\n\nimport (\n\t\"fmt\"\n\t\"github.com/other/lib\"\n)\n\nfunc func1() {}",
+		"file2.go": "package main\n\nimport (\n\tp \"github.com/myorg/pkg\"\n\t\"strings\"\n)\n\nfunc func2() {}",
+	}
+
+	tests := map[string]struct {
+		order    []string
+		expected string
+	}{
+		"Default": {
+			order: nil,
+			expected: "import (\n\t\"fmt\"\n\tp \"github.com/myorg/pkg\"\n" +
+				"\t\"github.com/other/lib\"\n\t\"strings\"\n)\n",
+		},
+		"OrgFirst": {
+			order: []string{"github.com/myorg/"},
+			expected: "import (\n\tp \"github.com/myorg/pkg\"\n\n\t\"fmt\"\n" +
+				"\t\"github.com/other/lib\"\n\t\"strings\"\n)\n",
+		},
+		"ManyGroups": {
+			order: []string{"github.com/myorg/", "github.com/"},
+			expected: "import (\n\tp \"github.com/myorg/pkg\"\n\n\t\"github.com/other/lib\"\n\n" +
+				"\t\"fmt\"\n\t\"strings\"\n)\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(gonverge.WithImportOrder(tc.order))
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Contains(output.String(), tc.expected)
+		})
+	}
+}
+
+func TestGoFileConverger_ImportsSorted(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"a.go": "package main\n\nimport (\n\t\"strings\"\n\t\"fmt\"\n)\n\nfunc zeta() { fmt.Println(strings.ToUpper(\"z\")) }\n",
+		"b.go": "package main\n\nimport (\n\t\"os\"\n\t\"bytes\"\n)\n\nfunc alpha() { _, _ = os.Stdout.Write(bytes.TrimSpace(nil)) }\n",
+		"c.go": "package main\n\nimport (\n\t\"io\"\n\t\"errors\"\n)\n\nvar mid = errors.Is(io.EOF, io.EOF)\n",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// Imports are sorted regardless of declaration
+	// sorting and of formatting the output.
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithNoFormat(true))
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+
+	out := output.String()
+	a.Contains(out, "import (\n\t\"bytes\"\n\t\"errors\"\n\t\"fmt\"\n\t\"io\"\n\t\"os\"\n\t\"strings\"\n)\n")
+	a.Less(strings.Index(out, "func zeta"), strings.Index(out, "func alpha"))
+	a.Less(strings.Index(out, "func alpha"), strings.Index(out, "var mid"))
+}
+
+func TestGoFileConverger_ModulePath(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\n\nimport (\n\t\"fmt\"\n\tmy \"github.com/myorg/mylib\"\n)\n\nfunc func1() { fmt.Println(my.Name) }",
+		"file2.go": "package main\n\nimport (\n\t\"github.com/myorg/mylib/pkg\"\n\t\"github.com/myorg/mylibx\"\n)\n\nfunc func2() { pkg.Do(mylibx.X) }",
+	}
+
+	tests := map[string]struct {
+		module   string
+		expected []string
+	}{
+		"Module": {
+			module: "github.com/myorg/mylib",
+			expected: []string{
+				`Merged file imports package "pkg" of its own module github.com/myorg/mylib`,
+				`Merged file imports package "." of its own module github.com/myorg/mylib`,
+			},
+		},
+		"TrailingSlash": {
+			module: "github.com/myorg/mylib/",
+			expected: []string{
+				`Merged file imports package "pkg" of its own module github.com/myorg/mylib`,
+				`Merged file imports package "." of its own module github.com/myorg/mylib`,
+			},
+		},
+		"OtherModule": {
+			module:   "github.com/other/lib",
+			expected: nil,
+		},
+		"NoModule": {
+			module:   "",
+			expected: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var logs bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithModulePath(tc.module),
+				gonverge.WithLogger(olog.NewLogger(olog.LevelWarn, olog.WithWriter(&logs))),
+			)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, io.Discard))
+
+			var got []string
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if i := strings.Index(line, "Merged file imports"); i >= 0 {
+					got = append(got, line[i:])
+				}
+			}
+			a.Equal(tc.expected, got)
+		})
+	}
+}
+
+func TestGoFileConverger_EmbedDirective(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nimport _ \"embed\"\n//go:embed data.txt\nvar Data string",
+		"file2.go": "package main\nimport \"embed\"\n//go:embed *.txt\nvar files embed.FS\nfunc main() {}",
+	}
+	expected := "package main\n\nimport (\n\t\"embed\"\n\t_ \"embed\"\n)\n\n" +
+		"//go:embed data.txt\nvar Data string\n\n//go:embed *.txt\nvar files embed.FS\n\nfunc main() {}\n"
+
+	tests := map[string]struct {
+		opts []gonverge.Option
+	}{
+		"Default": {
+			opts: nil,
+		},
+		"StripDocComments": {
+			opts: []gonverge.Option{gonverge.WithStripDocComments(true)},
+		},
+		"SortDeclarations": {
+			opts: []gonverge.Option{gonverge.WithSortDeclarations(true)},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_LineEndings(t *testing.T) {
+	a := assert.New(t)
+
+	src := "package main\n\nimport (\n\t\"fmt\"\n)\n\nfunc main() {\n\tfmt.Println(\"a\")\n}\n"
+	converge := func(src string) string {
+		dir := createTempDirWithFiles(t, map[string]string{
+			"file.go": src,
+		})
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				t.Fatalf("Failed to remove temp dir: %v", err)
+			}
+		}()
+
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(gonverge.WithNoFormat(true))
+		a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+		return output.String()
+	}
+
+	lf := converge(src)
+	crlf := converge(strings.ReplaceAll(src, "\n", "\r\n"))
+	a.Equal(lf, crlf)
+	a.NotContains(crlf, "\r")
+}
+
+func TestGoFileConverger_ByteOrderMark(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "\xef\xbb\xbfpackage main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger()
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+	a.Equal("package main\n\nfunc func1() {}\nfunc func2() {}\n", output.String())
+}
+
+func TestGoFileConverger_Reset(t *testing.T) {
+	a := assert.New(t)
+
+	dir1 := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	dir2 := createTempDirWithFiles(t, map[string]string{
+		"file2.go": "package util\nfunc func2() {}",
+	})
+	defer func() {
+		for _, dir := range []string{dir1, dir2} {
+			if err := os.RemoveAll(dir); err != nil {
+				t.Fatalf("Failed to remove temp dir: %v", err)
+			}
+		}
+	}()
+
+	var first, second bytes.Buffer
+	converger := gonverge.NewGoFileConverger()
+	a.NoError(converger.ConvergeFiles(context.Background(), dir1, &first))
+	converger.Reset()
+	a.NoError(converger.ConvergeFiles(context.Background(), dir2, &second))
+
+	a.Equal("package main\n\nfunc func1() {}\n", first.String())
+	a.Equal("package util\n\nfunc func2() {}\n", second.String())
+}
+
+func TestGoFileConverger_NoGoFiles(t *testing.T) {
+	tests := map[string]struct {
+		files map[string]string
+	}{
+		"EmptyDirectory": {
+			files: nil,
+		},
+		"OnlyNonGoFiles": {
+			files: map[string]string{
+				"file.txt":  "This is a text file",
+				"README.md": "# README",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger()
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Zero(output.Len())
+
+			converger = gonverge.NewGoFileConverger(gonverge.WithAllowEmpty(true))
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Zero(output.Len())
+
+			converger = gonverge.NewGoFileConverger(gonverge.WithAllowEmpty(false))
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			a.ErrorIs(err, gonverge.ErrNoFiles)
+			a.Zero(output.Len())
+		})
+	}
+}
+
+func TestGoFileConverger_StableOrder(t *testing.T) {
+	a := assert.New(t)
+
+	files := make(map[string]string)
+	var expected strings.Builder
+	expected.WriteString("package main\n\n")
+	for i := range 20 {
+		files[fmt.Sprintf("file%02d.go", i)] = fmt.Sprintf("package main\nfunc func%02d() {}", i)
+		expected.WriteString(fmt.Sprintf("func func%02d() {}\n", i))
+	}
+
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	for range 10 {
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(
+			gonverge.WithMaxWorkers(8),
+		)
+
+		err := converger.ConvergeFiles(context.Background(), dir, &output)
+		a.NoError(err)
+		a.Equal(expected.String(), output.String())
+	}
+}
+
+func TestGoFileConverger_DebugLogs(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var logs bytes.Buffer
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithLogger(olog.NewLogger(olog.LevelDebug, olog.WithWriter(&logs))),
+	)
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, io.Discard))
+
+	for _, name := range []string{"file1.go", "file2.go"} {
+		path := filepath.Join(dir, name)
+		a.Contains(logs.String(), "file path is valid: "+path)
+		a.Contains(logs.String(), "[consume]: Processing file: "+path)
+	}
+}
+
+func TestGoFileConverger_WalkDebugLogs(t *testing.T) {
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go":          "package main\nfunc func1() {}",
+		"zz_generated.go":   "package main\nfunc generated() {}",
+		"sub/file2.go":      "package main\nfunc func2() {}",
+		"vendor/vendor.go":  "package main\nfunc vendored() {}",
+		"sub/deep/file3.go": "package main\nfunc func3() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	tests := map[string]struct {
+		opts     []gonverge.Option
+		expected []string
+	}{
+		"Recursive": {
+			opts: []gonverge.Option{gonverge.WithRecursive(true)},
+			expected: []string{
+				"walking subdirectory: sub",
+				"walking subdirectory: sub/deep",
+				"skipping excluded subdirectory: vendor",
+			},
+		},
+		"FollowSymlinks": {
+			opts: []gonverge.Option{gonverge.WithRecursive(true), gonverge.WithFollowSymlinks(true)},
+			expected: []string{
+				"walking subdirectory: sub",
+				"walking subdirectory: sub/deep",
+				"skipping excluded subdirectory: vendor",
+			},
+		},
+		"NonRecursive": {
+			opts: nil,
+			expected: []string{
+				"skipping subdirectory (non-recursive): sub",
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			var logs bytes.Buffer
+			opts := append(tc.opts,
+				gonverge.WithExcludes([]*regexp.Regexp{regexp.MustCompile("^zz_")}),
+				gonverge.WithExcludeDirs([]*regexp.Regexp{regexp.MustCompile("^vendor$")}),
+				gonverge.WithLogger(olog.NewLogger(olog.LevelDebug, olog.WithWriter(&logs))),
+			)
+			converger := gonverge.NewGoFileConverger(opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, io.Discard))
+
+			expected := append(tc.expected,
+				"excluding file: "+filepath.Join(dir, "zz_generated.go")+" (matched by ^zz_)",
+				"file path is valid: "+filepath.Join(dir, "file1.go"),
+			)
+			for _, msg := range expected {
+				a.Contains(logs.String(), msg)
+			}
+		})
+	}
+}
+
+func TestGoFileConverger_Recursive(t *testing.T) {
+	tests := map[string]struct {
+		recursive bool
+		expected  string
+		logs      []string
+	}{
+		"NonRecursive": {
+			recursive: false,
+			expected:  "package main\n\nfunc func1() {}\n",
+			logs: []string{
+				"skipping subdirectory (non-recursive): sub",
+				"skipping non-Go file: " + filepath.Join("{dir}", "file.txt"),
+			},
+		},
+		"Recursive": {
+			recursive: true,
+			expected:  "package main\n\nfunc func1() {}\nfunc func2() {}\n",
+			logs: []string{
+				"skipping non-Go file: " + filepath.Join("{dir}", "file.txt"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file.txt": "This is a text file",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+			a.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+			a.NoError(os.WriteFile(filepath.Join(dir, "sub", "file2.go"),
+				[]byte("package main\nfunc func2() {}"), 0o644))
+
+			var logs, output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithRecursive(tc.recursive),
+				gonverge.WithLogger(olog.NewLogger(olog.LevelDebug, olog.WithWriter(&logs))),
+			)
+
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			a.NoError(err)
+			a.Equal(tc.expected, output.String())
+			for _, l := range tc.logs {
+				a.Contains(logs.String(), strings.ReplaceAll(l, "{dir}", dir))
+			}
+		})
+	}
+}
+
+func TestGoFileConverger_ExcludeDirs(t *testing.T) {
+	tests := map[string]struct {
+		followSymlinks bool
+	}{
+		"Walk":           {followSymlinks: false},
+		"FollowSymlinks": {followSymlinks: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+			})
+			for _, sub := range []string{"vendor/dep", "testdata", "pkg"} {
+				a.NoError(os.MkdirAll(filepath.Join(dir, filepath.FromSlash(sub)), 0o755))
+			}
+			a.NoError(os.WriteFile(filepath.Join(dir, "vendor", "dep", "dep.go"),
+				[]byte("package main\nfunc dep() {}"), 0o644))
+			a.NoError(os.WriteFile(filepath.Join(dir, "testdata", "data.go"),
+				[]byte("package main\nfunc data() {}"), 0o644))
+			a.NoError(os.WriteFile(filepath.Join(dir, "pkg", "file2.go"),
+				[]byte("package main\nfunc func2() {}"), 0o644))
+
+			var logs, output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithRecursive(true),
+				gonverge.WithFollowSymlinks(tc.followSymlinks),
+				gonverge.WithExcludeDirs([]*regexp.Regexp{
+					regexp.MustCompile("^vendor$"),
+					regexp.MustCompile("^testdata$"),
+				}),
+				gonverge.WithLogger(olog.NewLogger(olog.LevelDebug, olog.WithWriter(&logs))),
+			)
+
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal("package main\n\nfunc func1() {}\nfunc func2() {}\n", output.String())
+			a.Contains(logs.String(), "skipping excluded subdirectory: vendor")
+			a.Contains(logs.String(), "skipping excluded subdirectory: testdata")
+			a.NotContains(logs.String(), "dep.go")
+		})
+	}
+}
+
+func TestGoFileConverger_ExcludeTestFiles(t *testing.T) {
+	files := map[string]string{
+		"file1.go":      "package main\nfunc func1() {}",
+		"file1_test.go": "package main\nfunc test1() {}",
+	}
+
+	tests := map[string]struct {
+		opts     []gonverge.Option
+		expected string
+	}{
+		"Default": {
+			opts:     nil,
+			expected: "package main\n\nfunc func1() {}\n",
+		},
+		"Excluded": {
+			opts:     []gonverge.Option{gonverge.WithExcludeTestFiles(true)},
+			expected: "package main\n\nfunc func1() {}\n",
+		},
+		"Included": {
+			opts:     []gonverge.Option{gonverge.WithExcludeTestFiles(false)},
+			expected: "package main\n\nfunc func1() {}\nfunc test1() {}\n",
+		},
+		"Packages": {
+			opts:     []gonverge.Option{gonverge.WithPackages([]string{"main"})},
+			expected: "package main\n\nfunc func1() {}\nfunc test1() {}\n",
+		},
+		"PackagesExcluded": {
+			opts: []gonverge.Option{
+				gonverge.WithPackages([]string{"main"}),
+				gonverge.WithExcludeTestFiles(true),
+			},
+			expected: "package main\n\nfunc func1() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_MaxDepth(t *testing.T) {
+	tests := map[string]struct {
+		maxDepth int
+		expected []string
+	}{
+		"RootOnly": {
+			maxDepth: 0,
+			expected: []string{"func0"},
+		},
+		"OneLevel": {
+			maxDepth: 1,
+			expected: []string{"func0", "func1"},
+		},
+		"TwoLevels": {
+			maxDepth: 2,
+			expected: []string{"func0", "func1", "func2"},
+		},
+		"Unlimited": {
+			maxDepth: -1,
+			expected: []string{"func0", "func1", "func2", "func3"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file0.go": "package main\nfunc func0() {}",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			// Create a three-level tree: x/file1.go, x/y/file2.go, x/y/z/file3.go.
+			sub := dir
+			for i, d := range []string{"x", "y", "z"} {
+				sub = filepath.Join(sub, d)
+				a.NoError(os.Mkdir(sub, 0o755))
+				src := fmt.Sprintf("package main\nfunc func%d() {}", i+1)
+				a.NoError(os.WriteFile(filepath.Join(sub, fmt.Sprintf("file%d.go", i+1)), []byte(src), 0o644))
+			}
+
+			var expected strings.Builder
+			expected.WriteString("package main\n\n")
+			for _, fn := range tc.expected {
+				expected.WriteString("func " + fn + "() {}\n")
+			}
+
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithRecursive(true),
+				gonverge.WithMaxDepth(tc.maxDepth),
+			)
+
+			b, err := converger.DryRun(context.Background(), dir)
+			a.NoError(err)
+			a.Equal(expected.String(), string(b))
+		})
+	}
+}
+
+func TestGoFileConverger_FollowSymlinks(t *testing.T) {
+	tests := map[string]struct {
+		follow   bool
+		expected string
+	}{
+		"NotFollowed": {
+			follow:   false,
+			expected: "package main\n\nfunc func1() {}\n",
+		},
+		"Followed": {
+			follow:   true,
+			expected: "package main\n\nfunc func1() {}\nfunc func2() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+			})
+			shared := createTempDirWithFiles(t, map[string]string{
+				"shared.go": "package main\nfunc func2() {}",
+			})
+			defer func() {
+				for _, d := range []string{dir, shared} {
+					if err := os.RemoveAll(d); err != nil {
+						t.Fatalf("Failed to remove temp dir: %v", err)
+					}
+				}
+			}()
+
+			// Symlink the shared directory into the package, and link back
+			// to the package from the shared directory to form a cycle.
+			a.NoError(os.Symlink(shared, filepath.Join(dir, "shared")))
+			a.NoError(os.Symlink(dir, filepath.Join(shared, "cycle")))
+
+			// A broken symlink, like an editor's lock file, is skipped.
+			a.NoError(os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, ".#file1.go")))
+
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithRecursive(true),
+				gonverge.WithFollowSymlinks(tc.follow),
+			)
+
+			b, err := converger.DryRun(context.Background(), dir)
+			a.NoError(err)
+			a.Equal(tc.expected, string(b))
+		})
+	}
+}
+
+func TestGoFileConverger_SkipTimedOutFiles(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"slow.go":  "package main\nfunc slow() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// The hook blocks on slow.go until the test is done,
+	// like a file on an unresponsive network filesystem would.
+	release := make(chan struct{})
+	defer close(release)
+
+	var logs, output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithWorkerTimeout(50*time.Millisecond),
+		gonverge.WithSkipTimedOutFiles(true),
+		gonverge.WithPreProcessHook(func(path string, src []byte) ([]byte, error) {
+			if filepath.Base(path) == "slow.go" {
+				<-release
+			}
+			return src, nil
+		}),
+		gonverge.WithLogger(olog.NewLogger(olog.LevelWarn, olog.WithWriter(&logs))),
+	)
+
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+	a.Equal("package main\n\nfunc func1() {}\n", output.String())
+	a.Contains(logs.String(), "Skipping file that timed out")
+	a.Contains(logs.String(), "slow.go")
+}
+
+func TestGoFileConverger_MtimeCache(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// The hook is only run on files that are read.
+	var mu sync.Mutex
+	reads := make(map[string]int)
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithMtimeCache(true),
+		gonverge.WithPreProcessHook(func(path string, src []byte) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			reads[filepath.Base(path)]++
+			return src, nil
+		}),
+	)
+	converge := func() string {
+		t.Helper()
+		var output bytes.Buffer
+		a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+		converger.Reset()
+		return output.String()
+	}
+
+	expected := "package main\n\nfunc func1() {}\nfunc func2() {}\n"
+	a.Equal(expected, converge())
+	a.Equal(expected, converge())
+	a.Equal(map[string]int{"file1.go": 1, "file2.go": 1}, reads)
+
+	// Only the changed file is read again.
+	path := filepath.Join(dir, "file2.go")
+	a.NoError(os.WriteFile(path, []byte("package main\nfunc func3() {}"), 0o644))
+	mtime := time.Now().Add(time.Minute)
+	a.NoError(os.Chtimes(path, mtime, mtime))
+
+	a.Equal("package main\n\nfunc func1() {}\nfunc func3() {}\n", converge())
+	a.Equal(map[string]int{"file1.go": 1, "file2.go": 2}, reads)
+}
+
+func TestGoFileConverger_FileOrder(t *testing.T) {
+	tests := map[string]struct {
+		order    []string
+		expected string
+	}{
+		"Default": {
+			order:    nil,
+			expected: "package main\n\nfunc funcA() {}\nfunc funcB() {}\nfunc funcC() {}\n",
+		},
+		"Explicit": {
+			order:    []string{"b.go", "a.go"},
+			expected: "package main\n\nfunc funcB() {}\nfunc funcA() {}\nfunc funcC() {}\n",
+		},
+		"UnlistedFollow": {
+			order:    []string{"c.go"},
+			expected: "package main\n\nfunc funcC() {}\nfunc funcA() {}\nfunc funcB() {}\n",
+		},
+		"MissingAndDuplicates": {
+			order:    []string{"missing.go", "b.go", "b.go"},
+			expected: "package main\n\nfunc funcB() {}\nfunc funcA() {}\nfunc funcC() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"a.go": "package main\nfunc funcA() {}",
+				"b.go": "package main\nfunc funcB() {}",
+				"c.go": "package main\nfunc funcC() {}",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithFileOrder(tc.order),
+			)
+
+			b, err := converger.DryRun(context.Background(), dir)
+			a.NoError(err)
+			a.Equal(tc.expected, string(b))
+		})
+	}
+}
+
+func TestGoFileConverger_FileSize(t *testing.T) {
+	large := "package main\n\nvar large = `" + strings.Repeat("x", 1<<20) + "`\n"
+
+	tests := map[string]struct {
+		opts     []gonverge.Option
+		expected []string
+	}{
+		"NoLimits": {
+			expected: []string{"empty.go", "large.go", "small.go"},
+		},
+		"MinFileSize": {
+			opts:     []gonverge.Option{gonverge.WithMinFileSize(1)},
+			expected: []string{"large.go", "small.go"},
+		},
+		"MaxFileSize": {
+			opts:     []gonverge.Option{gonverge.WithMaxFileSize(1 << 10)},
+			expected: []string{"empty.go", "small.go"},
+		},
+		"BothLimits": {
+			opts: []gonverge.Option{
+				gonverge.WithMinFileSize(1),
+				gonverge.WithMaxFileSize(1 << 10),
+			},
+			expected: []string{"small.go"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"empty.go": "",
+				"small.go": "package main\nfunc small() {}",
+				"large.go": large,
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			files, err := gonverge.NewGoFileConverger(tc.opts...).ListFiles(context.Background(), dir)
+			a.NoError(err)
+
+			expected := make([]string, len(tc.expected))
+			for i, f := range tc.expected {
+				expected[i] = filepath.Join(dir, f)
+			}
+			a.Equal(expected, files)
+		})
+	}
+}
+
+func TestGoFileConverger_LargeFiles(t *testing.T) {
+	var decls, table strings.Builder
+	decls.WriteString("package main\n")
+	for i := range 5000 {
+		fmt.Fprintf(&decls, "\nfunc func%d() {}\n", i)
+	}
+	table.WriteString("package main\n\nvar table = []byte{")
+	for range 30000 {
+		table.WriteString("0xff, ")
+	}
+	table.WriteString("}\n")
+
+	files := map[string]string{
+		"decls.go": decls.String(),
+		"table.go": table.String(),
+	}
+
+	tests := map[string]struct {
+		opts []gonverge.Option
+		err  string
+	}{
+		"Default": {
+			opts: nil,
+		},
+		"SmallBuffer": {
+			opts: []gonverge.Option{gonverge.WithScanBufferSize(64 * 1024)},
+			err:  "failed to read file with a line longer than 65536 bytes",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			if tc.err != "" {
+				a.ErrorContains(err, tc.err)
+				return
+			}
+
+			a.NoError(err)
+			a.Contains(output.String(), "func func4999() {}\n")
+			a.Contains(output.String(), "var table = []byte{0xff, 0xff,")
+		})
+	}
+}
+
+func TestGoFileConverger_MaxFiles(t *testing.T) {
+	files := make(map[string]string)
+	for i := range 5 {
+		files[fmt.Sprintf("file%d.go", i)] = fmt.Sprintf("package main\nfunc func%d() {}", i)
+	}
+
+	tests := map[string]struct {
+		maxFiles       int
+		followSymlinks bool
+		err            error
+	}{
+		"Unlimited":            {maxFiles: 0},
+		"AtLimit":              {maxFiles: 5},
+		"OverLimit":            {maxFiles: 3, err: gonverge.ErrTooManyFiles},
+		"OverLimitFollowLinks": {maxFiles: 3, followSymlinks: true, err: gonverge.ErrTooManyFiles},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithMaxFiles(tc.maxFiles),
+				gonverge.WithFollowSymlinks(tc.followSymlinks),
+			)
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			if tc.err != nil {
+				a.ErrorIs(err, tc.err)
+				a.Zero(output.Len())
+				return
+			}
+			a.NoError(err)
+			a.Contains(output.String(), "func func4() {}")
+		})
+	}
+}
+
+func TestGoFileConverger_SrcFile(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+		"file3.go": "package main\nfunc func3() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithSrcFile([]string{
+		filepath.Join(dir, "file3.go"),
+		filepath.Join(dir, "file1.go"),
+	}))
+	a.NoError(converger.ConvergeFiles(context.Background(), t.TempDir(), &output))
+	a.Equal("package main\n\nfunc func1() {}\nfunc func3() {}\n", output.String())
+
+	converger = gonverge.NewGoFileConverger(gonverge.WithSrcFile([]string{
+		filepath.Join(dir, "missing.go"),
+	}))
+	err := converger.ConvergeFiles(context.Background(), dir, io.Discard)
+	a.ErrorIs(err, os.ErrNotExist)
+}
+
+func TestGoFileConverger_DryRun(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc func1() { fmt.Println() }",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	err := gonverge.NewGoFileConverger().ConvergeFiles(context.Background(), dir, &output)
+	a.NoError(err)
+
+	b, err := gonverge.NewGoFileConverger().DryRun(context.Background(), dir)
+	a.NoError(err)
+	a.Equal(output.Bytes(), b)
+
+	_, err = gonverge.NewGoFileConverger().DryRun(context.Background(), filepath.Join(dir, "missing"))
+	a.Error(err)
+}
+
+func TestGoFileConverger_ConvergeDir(t *testing.T) {
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	expected := "package main\n\nfunc func1() {}\n"
+
+	t.Run("OutFile", func(t *testing.T) {
+		a := assert.New(t)
+
+		out := filepath.Join(t.TempDir(), "out.go")
+		a.NoError(gonverge.NewGoFileConverger().ConvergeDir(context.Background(), dir, out))
+
+		b, err := os.ReadFile(out)
+		a.NoError(err)
+		a.Equal(expected, string(b))
+	})
+
+	t.Run("Stdout", func(t *testing.T) {
+		a := assert.New(t)
+
+		r, w, err := os.Pipe()
+		a.NoError(err)
+		stdout := os.Stdout
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+
+		err = gonverge.NewGoFileConverger().ConvergeDir(context.Background(), dir, "")
+		a.NoError(err)
+		a.NoError(w.Close())
+
+		b, err := io.ReadAll(r)
+		a.NoError(err)
+		a.Equal(expected, string(b))
+	})
+
+	t.Run("InvalidOutFile", func(t *testing.T) {
+		a := assert.New(t)
+
+		err := gonverge.NewGoFileConverger().ConvergeDir(context.Background(), dir, t.TempDir())
+		a.ErrorContains(err, "failed to create output file")
+	})
+
+	t.Run("FailedConvergeKeepsOutFile", func(t *testing.T) {
+		a := assert.New(t)
+
+		out := filepath.Join(t.TempDir(), "out.go")
+		a.NoError(os.WriteFile(out, []byte(expected), 0o600))
+
+		err := gonverge.NewGoFileConverger().ConvergeDir(context.Background(), filepath.Join(dir, "missing"), out)
+		a.Error(err)
+
+		b, err := os.ReadFile(out)
+		a.NoError(err)
+		a.Equal(expected, string(b))
+	})
+}
+
+func TestGoFileConverger_ConvergeFilesWithResult(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\nfunc func1() { fmt.Println() }",
+		"file2.go": "package main\nfunc func2() {}",
+		"skip.go":  "package main\nfunc skip() {}",
+		"doc.txt":  "not a Go file",
+	})
+
+	converger := gonverge.NewGoFileConverger(gonverge.WithExcludes([]*regexp.Regexp{
+		regexp.MustCompile("^skip"),
+	}))
+	b, res, err := converger.ConvergeFilesWithResult(context.Background(), dir)
+	a.NoError(err)
+	a.Equal(2, res.Files)
+	a.Equal([]string{filepath.Join(dir, "skip.go")}, res.Skipped)
+	a.Positive(res.Duration)
+
+	_, err = parser.ParseFile(token.NewFileSet(), "", b, parser.AllErrors)
+	a.NoError(err)
+	a.Contains(string(b), "func func2() {}")
+	a.NotContains(string(b), "func skip() {}")
+
+	_, _, err = gonverge.NewGoFileConverger().ConvergeFilesWithResult(context.Background(), filepath.Join(dir, "missing"))
+	a.Error(err)
+}
+
+func TestGoFileConverger_ConvergeToPackage(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\nimport \"fmt\"\n\n// func1 prints.\nfunc func1() { fmt.Println() }",
+		"file2.go": "package main\n\nimport s \"strings\"\n\nvar v = s.ToUpper(\"v\")",
+	})
+
+	pkg, err := gonverge.NewGoFileConverger().ConvergeToPackage(context.Background(), dir)
+	a.NoError(err)
+	a.Equal("main", pkg.Name)
+	a.ElementsMatch([]string{`"fmt"`, `s "strings"`}, pkg.Imports)
+	a.Len(pkg.Declarations, 2)
+	a.Equal([]string{"func1", "v"}, pkg.DeclarationNames())
+
+	// Add a declaration and an import programmatically.
+	pkg.Imports = append(pkg.Imports, "os")
+	pkg.Declarations = append(pkg.Declarations, &ast.FuncDecl{
+		Name: ast.NewIdent("exit"),
+		Type: &ast.FuncType{Params: &ast.FieldList{}},
+		Body: &ast.BlockStmt{List: []ast.Stmt{
+			&ast.ExprStmt{X: &ast.CallExpr{
+				Fun:  &ast.SelectorExpr{X: ast.NewIdent("os"), Sel: ast.NewIdent("Exit")},
+				Args: []ast.Expr{&ast.BasicLit{Kind: token.INT, Value: "1"}},
+			}},
+		}},
+	})
+
+	a.Equal([]string{"func1", "v", "exit"}, pkg.DeclarationNames())
+
+	b, err := pkg.Format()
+	a.NoError(err)
+
+	src := string(b)
+	a.Contains(src, "\"os\"\n")
+	a.Contains(src, "// func1 prints.\nfunc func1() { fmt.Println() }")
+	a.Contains(src, "func exit() {\n\tos.Exit(1)\n}\n")
+	_, err = parser.ParseFile(token.NewFileSet(), "", b, parser.AllErrors)
+	a.NoError(err)
+
+	_, err = gonverge.NewGoFileConverger().ConvergeToPackage(context.Background(), t.TempDir())
+	a.ErrorIs(err, gonverge.ErrNoFiles)
+}
+
+func TestChecksum(t *testing.T) {
+	a := assert.New(t)
+
+	a.Equal("sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", gonverge.Checksum(nil))
+
+	files := make(map[string]string)
+	for i := range 20 {
+		files[fmt.Sprintf("file%02d.go", i)] = fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc func%02d() { fmt.Println() }", i)
+	}
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// Converging the same files twice gives the same checksum.
+	first, err := gonverge.NewGoFileConverger().DryRun(context.Background(), dir)
+	a.NoError(err)
+	second, err := gonverge.NewGoFileConverger().DryRun(context.Background(), dir)
+	a.NoError(err)
+	a.Equal(gonverge.Checksum(first), gonverge.Checksum(second))
+}
+
+func TestGoFileConverger_ListFiles(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"b.go":       "package main\nfunc b() {}",
+		"a.go":       "package main\nfunc a() {}",
+		"exclude.go": "package main\nfunc exclude() {}",
+		"file.txt":   "This is a text file",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithExcludes([]*regexp.Regexp{regexp.MustCompile("exclude.go")}),
+	)
+
+	files, err := converger.ListFiles(context.Background(), dir)
+	a.NoError(err)
+	a.Equal([]string{
+		filepath.Join(dir, "a.go"),
+		filepath.Join(dir, "b.go"),
+	}, files)
+}
+
+func TestGoFileConverger_NoFormat(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nfunc   main( )   {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var formatted, unformatted bytes.Buffer
+
+	converger := gonverge.NewGoFileConverger()
+	err := converger.ConvergeFiles(context.Background(), dir, &formatted)
+	a.NoError(err)
+
+	converger = gonverge.NewGoFileConverger(gonverge.WithNoFormat(true))
+	err = converger.ConvergeFiles(context.Background(), dir, &unformatted)
+	a.NoError(err)
+
+	a.Equal("package main\n\nfunc main() {}\n", formatted.String())
+	a.Equal("package main\n\nfunc   main( )   {}\n", unformatted.String())
+
+	// The unformatted output should still type check.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", unformatted.Bytes(), parser.AllErrors)
+	a.NoError(err)
+
+	var conf types.Config
+	_, err = conf.Check("main", fset, []*ast.File{f}, nil)
+	a.NoError(err)
+}
+
+func TestGoFileConverger_NoFormatBlankLines(t *testing.T) {
+	tests := map[string]struct {
+		files    map[string]string
+		expected string
+	}{
+		"ImportsThenCode": {
+			files: map[string]string{
+				"a.go": "package main\nimport \"fmt\"\nfunc a() { fmt.Println() }",
+			},
+			expected: "package main\n\nimport \"fmt\"\n\nfunc a() { fmt.Println() }\n",
+		},
+		"ImportsThenBlankLines": {
+			files: map[string]string{
+				"a.go": "package main\n\nimport \"fmt\"\n\n\nfunc a() { fmt.Println() }",
+			},
+			expected: "package main\n\nimport \"fmt\"\n\nfunc a() { fmt.Println() }\n",
+		},
+		"NoImportsThenBlankLines": {
+			files: map[string]string{
+				"a.go": "package main\n\n\nfunc a() {}",
+				"b.go": "package main\nfunc b() {}",
+			},
+			expected: "package main\n\nfunc a() {}\nfunc b() {}\n",
+		},
+		"FirstFileWithoutImports": {
+			files: map[string]string{
+				"a.go": "package main\nfunc a() {}",
+				"b.go": "package main\nimport \"os\"\nvar b = os.Args",
+			},
+			expected: "package main\n\nimport \"os\"\n\nfunc a() {}\nvar b = os.Args\n",
+		},
+		"ImportsOnly": {
+			files: map[string]string{
+				"a.go": "package main\n\nimport _ \"embed\"\n",
+			},
+			expected: "package main\n\nimport _ \"embed\"\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(gonverge.WithNoFormat(true))
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_NoFormatSingleImport(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nimport    \"fmt\"\nfunc main() { fmt.Println() }",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var out bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithNoFormat(true))
+	err := converger.ConvergeFiles(context.Background(), dir, &out)
+	a.NoError(err)
+	a.Contains(out.String(), "\nimport \"fmt\"\n")
+}
+
+func TestGoFileConverger_Streaming(t *testing.T) {
+	files := map[string]string{
+		"a.go": "package main\n\nimport \"fmt\"\n\nfunc a() { fmt.Println(\"a\") }\n",
+		"b.go": "package main\n\nimport \"os\"\n\nvar b = os.Args\n",
+	}
+
+	tests := map[string]struct {
+		opts []gonverge.Option
+	}{
+		"NoFormat": {
+			opts: []gonverge.Option{gonverge.WithNoFormat(true)},
+		},
+		"NoFormatSorted": {
+			opts: []gonverge.Option{gonverge.WithNoFormat(true), gonverge.WithSortDeclarations(true)},
+		},
+		"Formatted": {
+			opts: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var want, got bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &want))
+
+			converger = gonverge.NewGoFileConverger(append(tc.opts, gonverge.WithStreaming(true))...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &got))
+
+			a.NotEmpty(got.String())
+			a.Equal(want.String(), got.String())
+		})
+	}
+}
+
+func TestGoFileConverger_BuildTag(t *testing.T) {
+	tests := map[string]struct {
+		tag      string
+		expected string
+		err      bool
+	}{
+		"Simple": {
+			tag:      "linux",
+			expected: "//go:build linux\n\npackage main\n\nfunc main() {}\n",
+		},
+		"Expression": {
+			tag:      "(linux||darwin) && !cgo",
+			expected: "//go:build (linux || darwin) && !cgo\n\npackage main\n\nfunc main() {}\n",
+		},
+		"Invalid": {
+			tag: "linux &&",
+			err: true,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file.go": "package main\nfunc main() {}",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var processed int
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithBuildTag(tc.tag),
+				gonverge.WithProgressCallback(func(string, int, int) {
+					processed++
+				}),
+			)
+
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			if tc.err {
+				a.ErrorContains(err, "invalid build tag")
+				a.Zero(processed)
+				a.Zero(output.Len())
+				return
+			}
+
+			a.NoError(err)
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_CustomFormatter(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nfunc main() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var called bool
+	upper := func(src []byte) ([]byte, error) {
+		called = true
+		return bytes.ToUpper(src), nil
+	}
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithCustomFormatter(upper),
+	)
+
+	err := converger.ConvergeFiles(context.Background(), dir, &output)
+	a.NoError(err)
+	a.True(called)
+	a.Equal("PACKAGE MAIN\n\nFUNC MAIN() {}\n", output.String())
+}
+
+func TestGoFileConverger_CustomFormatterError(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nfunc main() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	errFormat := errors.New("format failed")
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithCustomFormatter(func([]byte) ([]byte, error) {
+			return nil, errFormat
+		}),
+	)
+
+	var output bytes.Buffer
+	err := converger.ConvergeFiles(context.Background(), dir, &output)
+	a.ErrorIs(err, errFormat)
+	a.Zero(output.Len())
+}
+
+func TestGoFileConverger_NoFormatInvalidSource(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\nfunc main( {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var logs, output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithNoFormat(true),
+		gonverge.WithLogger(olog.NewLogger(olog.LevelWarn, olog.WithWriter(&logs))),
+	)
+
+	err := converger.ConvergeFiles(context.Background(), dir, &output)
+	a.NoError(err)
+	a.Equal("package main\n\nfunc main( {}\n", output.String())
+	a.Contains(logs.String(), "Unformatted output is not valid Go source")
+}
+
+func TestGoFileConverger_ProgressCallback(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+		"file3.go": "package main\nfunc func3() {}",
+		"file.txt": "This is a text file",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var (
+		calls = make(map[string]int)
+		dones []int
+	)
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithMaxWorkers(2),
+		gonverge.WithProgressCallback(func(path string, done, total int) {
+			calls[filepath.Base(path)]++
+			dones = append(dones, done)
+			a.Equal(3, total)
+		}),
+	)
+
+	err := converger.ConvergeFiles(context.Background(), dir, io.Discard)
+	a.NoError(err)
+	a.Equal(map[string]int{"file1.go": 1, "file2.go": 1, "file3.go": 1}, calls)
+	a.Equal([]int{1, 2, 3}, dones)
+}
+
+func TestGoFileConverger_ProgressInterval(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+		"file3.go": "package main\nfunc func3() {}",
+	}
+
+	tests := map[string]struct {
+		interval int
+		expected []string
+	}{
+		"EveryFile": {
+			interval: 1,
+			expected: []string{"Processed 1/3 files", "Processed 2/3 files", "Processed 3/3 files"},
+		},
+		"EverySecondFile": {
+			interval: 2,
+			expected: []string{"Processed 2/3 files"},
+		},
+		"Disabled": {
+			interval: 0,
+			expected: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var logs bytes.Buffer
+			converger := gonverge.NewGoFileConverger(
+				gonverge.WithProgressInterval(tc.interval),
+				gonverge.WithLogger(olog.NewLogger(olog.LevelInfo, olog.WithWriter(&logs))),
+			)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, io.Discard))
+
+			var got []string
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if i := strings.Index(line, "Processed "); i >= 0 {
+					got = append(got, line[i:])
+				}
+			}
+			a.Equal(tc.expected, got)
+		})
+	}
+}
+
+func TestGoFileConverger_WorkerError(t *testing.T) {
+	a := assert.New(t)
+
+	files := map[string]string{
+		// A line longer than the scanner buffer makes processing fail.
+		"bad.go": "package main\n// " + strings.Repeat("x", 2<<20) + "\n",
+	}
+	for i := range 20 {
+		files[fmt.Sprintf("file%d.go", i)] = fmt.Sprintf("package main\nfunc func%d() {}", i)
+	}
+
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithMaxWorkers(4))
+
+	err := converger.ConvergeFiles(context.Background(), dir, &output)
+	a.ErrorIs(err, bufio.ErrTooLong)
+	a.Empty(output.String())
+}
+
+func TestGoFileConverger_ConcurrentWorkerErrors(t *testing.T) {
+	a := assert.New(t)
+
+	files := make(map[string]string)
+	for i := range 20 {
+		files[fmt.Sprintf("file%d.go", i)] = fmt.Sprintf("package main\nfunc func%d() {}", i)
+	}
+
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// Every file fails, so the workers run into errors at the
+	// same time; one of them must always be returned.
+	errHook := errors.New("hook failed")
+	for range 50 {
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(
+			gonverge.WithMaxWorkers(8),
+			gonverge.WithPreProcessHook(func(string, []byte) ([]byte, error) {
+				return nil, errHook
+			}),
+		)
+
+		err := converger.ConvergeFiles(context.Background(), dir, &output)
+		a.ErrorIs(err, errHook)
+		a.Empty(output.String())
+	}
+}
+
+func TestGoFileConverger_SingleWorkerErrorPropagates(t *testing.T) {
+	a := assert.New(t)
+
+	files := make(map[string]string)
+	for i := range 20 {
+		files[fmt.Sprintf("file%d.go", i)] = fmt.Sprintf("package main\nfunc func%d() {}", i)
+	}
+
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	// Only one file fails while the other workers keep succeeding,
+	// so the error must not get lost among the successful results.
+	errHook := errors.New("hook failed")
+	for range 50 {
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(
+			gonverge.WithMaxWorkers(4),
+			gonverge.WithPreProcessHook(func(path string, src []byte) ([]byte, error) {
+				if filepath.Base(path) == "file13.go" {
+					return nil, errHook
+				}
+				return src, nil
+			}),
+		)
+
+		err := converger.ConvergeFiles(context.Background(), dir, &output)
+		a.ErrorIs(err, errHook)
+		a.Empty(output.String())
+	}
+}
+
+func TestGoFileConverger_FileAttribution(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\n\n// func1 is documented.\nfunc func1() {}",
+		"file2.go": "package main\nfunc func2() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	a.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+	a.NoError(os.WriteFile(filepath.Join(dir, "sub", "file3.go"),
+		[]byte("package main\nfunc func3() {}"), 0o644))
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(
+		gonverge.WithRecursive(true),
+		gonverge.WithFileAttribution(true),
+	)
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+
+	expected := "package main\n\n" +
+		"// Source: file1.go\n\n// func1 is documented.\nfunc func1() {}\n\n" +
+		"// Source: file2.go\n\nfunc func2() {}\n\n" +
+		"// Source: sub/file3.go\n\nfunc func3() {}\n"
+	a.Equal(expected, output.String())
+}
+
+func TestGoFileConverger_Separator(t *testing.T) {
+	tests := map[string]struct {
+		separator string
+		expected  string
+	}{
+		"LineComment": {
+			separator: "// --- %s ---",
+			expected: "package main\n\n// --- file1.go ---\n\nfunc func1() {}\n\n" +
+				"// --- file2.go ---\n\nfunc func2() {}\n",
+		},
+		"BlockComment": {
+			separator: "/* %s */",
+			expected: "package main\n\n/* file1.go */\n\nfunc func1() {}\n\n" +
+				"/* file2.go */\n\nfunc func2() {}\n",
+		},
+		"NotAComment": {
+			separator: "=== %s ===",
+			expected: "package main\n\n// === file1.go ===\n\nfunc func1() {}\n\n" +
+				"// === file2.go ===\n\nfunc func2() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file1.go": "package main\nfunc func1() {}",
+				"file2.go": "package main\nfunc func2() {}",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(gonverge.WithSeparator(tc.separator))
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_OutputPackageName(t *testing.T) {
+	tests := map[string]struct {
+		files    map[string]string
+		contains []string
+		excludes []string
+	}{
+		"SinglePackage": {
+			files: map[string]string{
+				"file1.go": "package foo\nfunc func1() {}",
+			},
+			contains: []string{"package merged\n", "func func1() {}"},
+		},
+		"QualifiedReferences": {
+			files: map[string]string{
+				"bar.go": "package bar\n\nimport (\n\t\"example.com/foo\"\n\t\"fmt\"\n)\n\n" +
+					"func Use() { fmt.Println(foo.New(), foo.Thing{}) }",
+				"foo.go": "package foo\n\ntype Thing struct{}\n\nfunc New() Thing { return Thing{} }",
+			},
+			contains: []string{"package merged\n", "\"fmt\"", "func Use() { fmt.Println(New(), Thing{}) }"},
+			excludes: []string{"example.com/foo", "foo."},
+		},
+		"SingleImport": {
+			files: map[string]string{
+				"bar.go": "package bar\n\nimport f \"example.com/foo\"\n\nvar t = f.Thing{}",
+				"foo.go": "package foo\n\ntype Thing struct{}",
+			},
+			contains: []string{"package merged\n", "var t = Thing{}"},
+			excludes: []string{"import", "f.Thing"},
+		},
+		"UndeclaredReference": {
+			files: map[string]string{
+				"bar.go": "package bar\n\nimport \"example.com/foo\"\n\nvar (\n\tt = foo.Thing{}\n\to = foo.Other{}\n)",
+				"foo.go": "package foo\n\ntype Thing struct{}",
+			},
+			contains: []string{"import \"example.com/foo\"", "t = Thing{}", "o = foo.Other{}"},
+		},
+		"ShadowedImport": {
+			files: map[string]string{
+				"bar.go": "package bar\n\nimport \"example.com/foo\"\n\nvar t = foo.Thing{}\n\n" +
+					"func use() int { foo := struct{ Thing int }{}; return foo.Thing }",
+				"foo.go": "package foo\n\ntype Thing struct{}",
+			},
+			contains: []string{"var t = Thing{}", "return foo.Thing"},
+			excludes: []string{"example.com/foo"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			converger := gonverge.NewGoFileConverger(gonverge.WithOutputPackageName("merged"))
+			a.NoError(converger.Validate(context.Background(), dir))
+
+			var output bytes.Buffer
+			converger.Reset()
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			for _, s := range tc.contains {
+				a.Contains(output.String(), s)
+			}
+			for _, s := range tc.excludes {
+				a.NotContains(output.String(), s)
+			}
+		})
+	}
+}
+
+func TestGoFileConverger_InitCollisions(t *testing.T) {
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nvar a int\nfunc init() { a = 1 }",
+		"file2.go": "package main\nfunc init() { a = 2 }",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+	paths := filepath.Join(dir, "file1.go") + ", " + filepath.Join(dir, "file2.go")
+
+	tests := map[string]struct {
+		opts    []gonverge.Option
+		warning bool
+		err     error
+	}{
+		"Disabled": {
+			opts: nil,
+		},
+		"Warning": {
+			opts:    []gonverge.Option{gonverge.WithDetectInitCollisions(true)},
+			warning: true,
+		},
+		"Fatal": {
+			opts: []gonverge.Option{gonverge.WithInitCollisionFatal(true)},
+			err:  gonverge.ErrInitCollision,
+		},
 	}
 
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
-			dir := createTempDirWithFiles(t, tc.files)
+			a := assert.New(t)
+
+			var logs, output bytes.Buffer
+			lg := olog.NewLogger(olog.LevelWarn, olog.WithWriter(&logs))
+			converger := gonverge.NewGoFileConverger(append(tc.opts, gonverge.WithLogger(lg))...)
+
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			if tc.err != nil {
+				a.ErrorIs(err, tc.err)
+				a.ErrorContains(err, paths)
+				a.Zero(output.Len())
+				return
+			}
+			a.NoError(err)
+			a.Contains(output.String(), "func init() { a = 2 }")
+			if tc.warning {
+				a.Contains(logs.String(), "Multiple init functions declared in "+paths)
+			} else {
+				a.Empty(logs.String())
+			}
+		})
+	}
+}
+
+func TestGoFileConverger_OutputHeader(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package main\n\n// func1 does things.\nfunc func1() {}",
+	}
+
+	tests := map[string]struct {
+		header   string
+		opts     []gonverge.Option
+		expected string
+		err      string
+	}{
+		"Comment": {
+			header:   "//nolint:all",
+			expected: "//nolint:all\npackage main\n\n// func1 does things.\nfunc func1() {}\n",
+		},
+		"BuildTag": {
+			header:   "// SPDX-License-Identifier: MIT\n\n",
+			opts:     []gonverge.Option{gonverge.WithBuildTag("linux")},
+			expected: "// SPDX-License-Identifier: MIT\n\n//go:build linux\n\npackage main\n\n// func1 does things.\nfunc func1() {}\n",
+		},
+		"StripComments": {
+			header:   "//nolint:all\n\n",
+			opts:     []gonverge.Option{gonverge.WithStripComments(true)},
+			expected: "//nolint:all\n\npackage main\n\nfunc func1() {}\n",
+		},
+		"Invalid": {
+			header: "not a comment",
+			err:    "invalid output header",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
 			defer func() {
 				if err := os.RemoveAll(dir); err != nil {
 					t.Fatalf("Failed to remove temp dir: %v", err)
 				}
 			}()
 
-			// Use only one worker to make the test deterministic.
-			opts := []gonverge.Option{
-				gonverge.WithMaxWorkers(1),
-			}
-			if len(tc.excludes) > 0 {
-				opts = append(opts, gonverge.WithExcludes(tc.excludes))
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(append(tc.opts, gonverge.WithOutputHeader(tc.header))...)
+			err := converger.ConvergeFiles(context.Background(), dir, &output)
+			if tc.err != "" {
+				a.ErrorContains(err, tc.err)
+				return
 			}
+
+			a.NoError(err)
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_OutputSuffix(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}",
+		"file2.go": "package main\nfunc registerAll() {}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithOutputSuffix("func init() { registerAll() }\n"))
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+	a.True(strings.HasSuffix(output.String(), "func registerAll() {}\n\nfunc init() { registerAll() }\n"),
+		"suffix not at the end of output:\n%s", output.String())
+
+	for _, code := range []string{"func init() {", "x := 1", "package other"} {
+		output.Reset()
+		converger = gonverge.NewGoFileConverger(gonverge.WithOutputSuffix(code))
+		err := converger.ConvergeFiles(context.Background(), dir, &output)
+		a.ErrorContains(err, "invalid output suffix", "code: %q", code)
+		a.Empty(output.String())
+	}
+}
+
+func TestGoFileConverger_Deduplication(t *testing.T) {
+	tests := map[string]struct {
+		files    map[string]string
+		expected string
+	}{
+		"Function": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc helper() {}\nfunc func1() { helper() }",
+				"file2.go": "package main\nfunc helper() {}\nfunc func2() { helper() }",
+			},
+			expected: "package main\n\nfunc helper() {}\n\nfunc func1() { helper() }\n\nfunc func2() { helper() }\n",
+		},
+		"TypeAndMethod": {
+			files: map[string]string{
+				"file1.go": "package main\ntype T struct{}\nfunc (T) M() {}",
+				"file2.go": "package main\ntype T struct{}\nfunc (*T) M() {}",
+			},
+			expected: "package main\n\ntype T struct{}\n\nfunc (T) M() {}\n",
+		},
+		"PartialGroup": {
+			files: map[string]string{
+				"file1.go": "package main\nvar a = 1",
+				"file2.go": "package main\nvar (\n\ta = 1\n\tb = 2\n)",
+			},
+			expected: "package main\n\nvar a = 1\nvar (\n\ta = 1\n\tb = 2\n)\n",
+		},
+		"InitFunctions": {
+			files: map[string]string{
+				"file1.go": "package main\nfunc init() {}",
+				"file2.go": "package main\nfunc init() {}",
+			},
+			expected: "package main\n\nfunc init() {}\nfunc init() {}\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, tc.files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(gonverge.WithDeduplication(true))
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_OutputMode(t *testing.T) {
+	tests := map[string]struct {
+		mode     gonverge.OutputMode
+		expected string
+	}{
+		"Full": {
+			mode:     gonverge.OutputModeFull,
+			expected: "//go:build linux\n\npackage main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+		},
+		"Snippet": {
+			mode:     gonverge.OutputModeSnippet,
+			expected: "import \"fmt\"\n\nfunc main() { fmt.Println() }\n",
+		},
+		"Declarations": {
+			mode:     gonverge.OutputModeDeclarations,
+			expected: "func main() { fmt.Println() }\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file.go": "package main\nimport \"fmt\"\nfunc main() { fmt.Println() }",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
 			converger := gonverge.NewGoFileConverger(
-				opts...,
+				gonverge.WithBuildTag("linux"),
+				gonverge.WithOutputMode(tc.mode),
 			)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
 
-			var output bytes.Buffer
+func TestGoFileConverger_InvalidOutputMode(t *testing.T) {
+	a := assert.New(t)
+
+	converger := gonverge.NewGoFileConverger(gonverge.WithOutputMode("partial"))
+	err := converger.ConvergeFiles(context.Background(), t.TempDir(), io.Discard)
+	a.ErrorContains(err, "invalid output mode")
+}
+
+func TestGoFileConverger_ProcessHooks(t *testing.T) {
+	replace := func(src []byte) []byte {
+		return bytes.ReplaceAll(src, []byte("PLACEHOLDER"), []byte("replaced"))
+	}
+
+	tests := map[string]struct {
+		opt      gonverge.Option
+		expected string
+		err      string
+	}{
+		"PreProcess": {
+			opt: gonverge.WithPreProcessHook(func(path string, src []byte) ([]byte, error) {
+				if filepath.Base(path) != "file.go" {
+					return nil, fmt.Errorf("unexpected path: %s", path)
+				}
+				return replace(src), nil
+			}),
+			expected: "package main\n\nfunc replaced() {}\n",
+		},
+		"PostProcess": {
+			opt: gonverge.WithPostProcessHook(func(src []byte) ([]byte, error) {
+				return replace(src), nil
+			}),
+			expected: "package main\n\nfunc replaced() {}\n",
+		},
+		"PreProcessError": {
+			opt: gonverge.WithPreProcessHook(func(string, []byte) ([]byte, error) {
+				return nil, errors.New("hook failed")
+			}),
+			err: "hook failed",
+		},
+		"PostProcessError": {
+			opt: gonverge.WithPostProcessHook(func([]byte) ([]byte, error) {
+				return nil, errors.New("hook failed")
+			}),
+			err: "hook failed",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{
+				"file.go": "package main\nfunc PLACEHOLDER() {}",
+			})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
 
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opt)
 			err := converger.ConvergeFiles(context.Background(), dir, &output)
-			a.False(tc.err && err == nil)
+			if tc.err != "" {
+				a.ErrorContains(err, tc.err)
+				a.Empty(output.String())
+				return
+			}
+			a.NoError(err)
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_RenameExported(t *testing.T) {
+	files := map[string]string{
+		"file1.go": "package lib\n\n// FooFunc does foo.\nfunc FooFunc() Bar { return Bar{Baz: Max} }\n\n" +
+			"const Max = 1\n\nfunc helper() { FooFunc() }",
+		"file2.go": "package lib\n\ntype Bar struct{ Baz int }\n\nfunc (b Bar) String() string { return \"\" }\n\n" +
+			"func shadow() { FooFunc := 1; _ = FooFunc }",
+	}
+
+	tests := map[string]struct {
+		opts     []gonverge.Option
+		expected string
+	}{
+		"Suffix": {
+			opts: []gonverge.Option{gonverge.WithExportedSuffix("V2")},
+			expected: "package lib\n\n// FooFunc does foo.\nfunc FooFuncV2() BarV2 { return BarV2{Baz: MaxV2} }\n\n" +
+				"const MaxV2 = 1\n\nfunc helper() { FooFuncV2() }\n\ntype BarV2 struct{ Baz int }\n\n" +
+				"func (b BarV2) String() string { return \"\" }\n\nfunc shadow() { FooFunc := 1; _ = FooFunc }\n",
+		},
+		"Prefix": {
+			opts: []gonverge.Option{gonverge.WithExportedPrefix("X")},
+			expected: "package lib\n\n// FooFunc does foo.\nfunc XFooFunc() XBar { return XBar{Baz: XMax} }\n\n" +
+				"const XMax = 1\n\nfunc helper() { XFooFunc() }\n\ntype XBar struct{ Baz int }\n\n" +
+				"func (b XBar) String() string { return \"\" }\n\nfunc shadow() { FooFunc := 1; _ = FooFunc }\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, files)
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+			a.Equal(tc.expected, output.String())
+		})
+	}
+}
+
+func TestGoFileConverger_RenameExportedStructLiteral(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package lib\n\ntype Config struct{ Timeout int }\n\nconst Timeout = 5\n\n" +
+			"func New() Config { return Config{Timeout: Timeout} }\n\n" +
+			"var byTimeout = map[int]Config{Timeout: {Timeout: Timeout}}",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	var output bytes.Buffer
+	converger := gonverge.NewGoFileConverger(gonverge.WithExportedSuffix("V2"))
+	a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+	a.Equal("package lib\n\ntype ConfigV2 struct{ Timeout int }\n\nconst TimeoutV2 = 5\n\n"+
+		"func NewV2() ConfigV2 { return ConfigV2{Timeout: TimeoutV2} }\n\n"+
+		"var byTimeout = map[int]ConfigV2{TimeoutV2: {Timeout: TimeoutV2}}\n", output.String())
+
+	// The renamed source must still compile.
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", output.Bytes(), 0)
+	if !a.NoError(err) {
+		return
+	}
+	_, err = new(types.Config).Check("lib", fset, []*ast.File{f}, nil)
+	a.NoError(err)
+}
+
+func TestGoFileConverger_SortDeclarations(t *testing.T) {
+	a := assert.New(t)
+
+	first := "package main\n\nfunc main() {}\n\n// zeta is documented.\nfunc zeta() {}\n"
+	second := "package main\n\nfunc init() {}\n\ntype Alpha struct{}\n\nfunc (Alpha) beta() {}\n\nvar gamma = 1\n"
+
+	orderings := []map[string]string{
+		{"a.go": first, "b.go": second},
+		{"a.go": second, "b.go": first},
+	}
+
+	expected := "package main\n\n" +
+		"type Alpha struct{}\n\n" +
+		"func (Alpha) beta() {}\n\n" +
+		"var gamma = 1\n\n" +
+		"// zeta is documented.\nfunc zeta() {}\n\n" +
+		"func init() {}\n\n" +
+		"func main() {}\n"
+
+	for _, files := range orderings {
+		dir := createTempDirWithFiles(t, files)
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				t.Fatalf("Failed to remove temp dir: %v", err)
+			}
+		}()
+
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(
+			gonverge.WithMaxWorkers(1),
+			gonverge.WithSortDeclarations(true),
+		)
+
+		err := converger.ConvergeFiles(context.Background(), dir, &output)
+		a.NoError(err)
+		a.Equal(expected, output.String())
+	}
+}
+
+func TestGoFileConverger_StripComments(t *testing.T) {
+	src := "package main\n\n" +
+		"//go:generate echo\n\n" +
+		"// Exported is documented.\nfunc Exported() {\n\t// inline comment\n\tunexported() // trailing comment\n}\n\n" +
+		"// unexported is documented.\nfunc unexported() {}\n\n" +
+		"/* Value is documented. */\nvar Value = 1\n"
+
+	tests := map[string]struct {
+		opts     []gonverge.Option
+		expected string
+	}{
+		"StripComments": {
+			opts: []gonverge.Option{gonverge.WithStripComments(true)},
+			expected: "package main\n\n//go:generate echo\n\n" +
+				"func Exported() {\n\n\tunexported()\n}\n\n" +
+				"func unexported() {}\n\n" +
+				"var Value = 1\n",
+		},
+		"StripDocComments": {
+			opts: []gonverge.Option{gonverge.WithStripDocComments(true)},
+			expected: "package main\n\n//go:generate echo\n\n" +
+				"func Exported() {\n\t// inline comment\n\tunexported() // trailing comment\n}\n\n" +
+				"// unexported is documented.\nfunc unexported() {}\n\n" +
+				"var Value = 1\n",
+		},
+		"StripCommentsSorted": {
+			opts: []gonverge.Option{
+				gonverge.WithStripComments(true),
+				gonverge.WithSortDeclarations(true),
+			},
+			expected: "package main\n\n//go:generate echo\n\n" +
+				"func Exported() {\n\n\tunexported()\n}\n\n" +
+				"func unexported() {}\n\n" +
+				"var Value = 1\n",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			dir := createTempDirWithFiles(t, map[string]string{"file.go": src})
+			defer func() {
+				if err := os.RemoveAll(dir); err != nil {
+					t.Fatalf("Failed to remove temp dir: %v", err)
+				}
+			}()
+
+			var output bytes.Buffer
+			converger := gonverge.NewGoFileConverger(tc.opts...)
+			a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
 			a.Equal(tc.expected, output.String())
+
+			_, err := format.Source(output.Bytes())
+			a.NoError(err)
 		})
 	}
 }
 
+func TestGoFileConverger_Validate(t *testing.T) {
+	a := assert.New(t)
+
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file1.go": "package main\nfunc func1() {}\nfunc init() {}",
+		"file2.go": "package main\nfunc func1() {}\nfunc init() {}\nfunc (t T) func1() {}",
+		"file3.go": "package main\ntype T struct{}\nvar _ = 1\nvar _ = 2",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	converger := gonverge.NewGoFileConverger()
+	err := converger.Validate(context.Background(), dir)
+	a.ErrorIs(err, gonverge.ErrValidation)
+	a.ErrorContains(err, "duplicate declaration: func1 (declared in "+
+		filepath.Join(dir, "file1.go")+", "+filepath.Join(dir, "file2.go")+")")
+	a.NotContains(err.Error(), "duplicate declaration: init")
+	a.NotContains(err.Error(), "duplicate declaration: _")
+	a.NotContains(err.Error(), "duplicate declaration: T.func1")
+}
+
 func TestGoFileConverger_DirectoryNotFound(t *testing.T) {
 	a := assert.New(t)
 
@@ -108,6 +2525,140 @@ func TestGoFileConverger_DirectoryNotFound(t *testing.T) {
 	a.Error(err)
 }
 
+func TestGoFileConverger_ConcurrencyLimit(t *testing.T) {
+	a := assert.New(t)
+
+	files := make(map[string]string)
+	for i := range 50 {
+		files[fmt.Sprintf("file%02d.go", i)] = fmt.Sprintf("package main\nfunc func%02d() {}", i)
+	}
+	dir := createTempDirWithFiles(t, files)
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	converge := func(limit int) string {
+		var output bytes.Buffer
+		converger := gonverge.NewGoFileConverger(
+			gonverge.WithMaxWorkers(8),
+			gonverge.WithConcurrencyLimit(limit),
+		)
+		a.NoError(converger.ConvergeFiles(context.Background(), dir, &output))
+		return output.String()
+	}
+
+	expected := converge(8)
+	a.Contains(expected, "func func49() {}")
+	a.Equal(expected, converge(1))
+
+	const fdDir = "/proc/self/fd"
+	if _, err := os.Stat(fdDir); err != nil {
+		t.Skip("open file descriptors can't be counted on this platform")
+	}
+	countFDs := func() int {
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			t.Fatalf("Failed to read open file descriptors: %v", err)
+		}
+		return len(entries)
+	}
+
+	// Disable the garbage collector so finalizers
+	// can't close any leaked file descriptors.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	before := countFDs()
+	converge(1)
+	a.LessOrEqual(countFDs(), before)
+}
+
+func TestGoFileConverger_ClosesFilesOnError(t *testing.T) {
+	a := assert.New(t)
+
+	const fdDir = "/proc/self/fd"
+	if _, err := os.Stat(fdDir); err != nil {
+		t.Skip("open file descriptors can't be counted on this platform")
+	}
+
+	// A line longer than the scanner buffer makes processing fail.
+	longLine := "// " + strings.Repeat("x", 2<<20)
+	dir := createTempDirWithFiles(t, map[string]string{
+		"file.go": "package main\n" + longLine + "\n",
+	})
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			t.Fatalf("Failed to remove temp dir: %v", err)
+		}
+	}()
+
+	countFDs := func() int {
+		entries, err := os.ReadDir(fdDir)
+		if err != nil {
+			t.Fatalf("Failed to read open file descriptors: %v", err)
+		}
+		return len(entries)
+	}
+
+	// Disable the garbage collector so finalizers
+	// can't close any leaked file descriptors.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+
+	const runs = 20
+	before := countFDs()
+	for range runs {
+		converger := gonverge.NewGoFileConverger(gonverge.WithMaxWorkers(1))
+		err := converger.ConvergeFiles(context.Background(), dir, io.Discard)
+		a.Error(err)
+	}
+	a.Less(countFDs()-before, runs)
+}
+
+func BenchmarkConvergeFiles10(b *testing.B) {
+	benchmarkConvergeFiles(b, 10)
+}
+
+func BenchmarkConvergeFiles100(b *testing.B) {
+	benchmarkConvergeFiles(b, 100)
+}
+
+func BenchmarkConvergeFiles1000(b *testing.B) {
+	benchmarkConvergeFiles(b, 1000)
+}
+
+// benchmarkConvergeFiles benchmarks converging a directory
+// of n files with different numbers of workers.
+func benchmarkConvergeFiles(b *testing.B, n int) {
+	b.Helper()
+
+	dir := b.TempDir()
+	for i := range n {
+		src := fmt.Sprintf("package main\n\nimport \"fmt\"\n\nfunc func%d() { fmt.Println(%d) }\n", i, i)
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%d.go", i)), []byte(src), 0o644); err != nil {
+			b.Fatalf("Failed to write to temp file: %v", err)
+		}
+	}
+
+	// NumCPU may be 1 or 4 as well, so skip duplicates.
+	counts := []int{1, 4, runtime.NumCPU()}
+	slices.Sort(counts)
+
+	for _, workers := range slices.Compact(counts) {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				// A converger can only be used once, so
+				// each iteration needs a new one.
+				converger := gonverge.NewGoFileConverger(gonverge.WithMaxWorkers(workers))
+				if err := converger.ConvergeFiles(context.Background(), dir, io.Discard); err != nil {
+					b.Fatalf("Failed to converge files: %v", err)
+				}
+			}
+		})
+	}
+}
+
 // createTempDirWithFiles creates a temporary directory with the given files for testing.
 func createTempDirWithFiles(t *testing.T, files map[string]string) string {
 	t.Helper()
@@ -119,6 +2670,9 @@ func createTempDirWithFiles(t *testing.T, files map[string]string) string {
 
 	for filename, content := range files {
 		fp := filepath.Join(dir, filename)
+		if err = os.MkdirAll(filepath.Dir(fp), 0o755); err != nil {
+			t.Fatalf("Failed to create temp subdir: %v", err)
+		}
 		if err = os.WriteFile(fp, []byte(content), 0o644); err != nil {
 			t.Fatalf("Failed to write to temp file: %v", err)
 		}
@@ -0,0 +1,63 @@
+package gonverge
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+)
+
+// OutputMode determines which parts of the
+// converged Go file are written as output.
+type OutputMode string
+
+const (
+	// OutputModeFull writes the full Go file, including
+	// the package clause and imports. This is the default.
+	OutputModeFull OutputMode = "full"
+
+	// OutputModeSnippet omits the package clause (and anything
+	// before it, like build constraints) but keeps the imports.
+	OutputModeSnippet OutputMode = "snippet"
+
+	// OutputModeDeclarations omits the package clause and the
+	// imports, so only the top-level declarations are written.
+	OutputModeDeclarations OutputMode = "declarations"
+)
+
+// validate returns an error if the output mode is not supported.
+func (m OutputMode) validate() error {
+	switch m {
+	case OutputModeFull, OutputModeSnippet, OutputModeDeclarations:
+		return nil
+	default:
+		return fmt.Errorf("invalid output mode %q: must be %q, %q or %q",
+			m, OutputModeFull, OutputModeSnippet, OutputModeDeclarations)
+	}
+}
+
+// trimOutput cuts the given Go source down to the parts included
+// by the given output mode. The source must be a full Go file, so
+// it can be formatted before it is cut.
+func trimOutput(src []byte, mode OutputMode) ([]byte, error) {
+	if mode == OutputModeFull {
+		return src, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ImportsOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	// With ImportsOnly, the only declarations
+	// parsed are the import declarations.
+	end := f.Name.End()
+	if mode == OutputModeDeclarations {
+		for _, d := range f.Decls {
+			end = max(end, d.End())
+		}
+	}
+
+	return bytes.TrimLeft(src[fset.Position(end).Offset:], "\n"), nil
+}
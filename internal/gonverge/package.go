@@ -0,0 +1,145 @@
+package gonverge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// Package is the merged result of converging a directory, before it
+// is formatted into source code. Its fields may be modified freely,
+// e.g. to add declarations, before calling Format to render it.
+type Package struct {
+	// Name is the name of the package.
+	Name string
+
+	// Imports are the import specs of the package, each
+	// a quoted import path optionally preceded by a name,
+	// e.g. `"fmt"` or `f "fmt"`. An unquoted path is
+	// quoted when the package is formatted.
+	Imports []string
+
+	// Declarations are the top-level declarations of
+	// the package, excluding the import declarations.
+	Declarations []ast.Decl
+
+	// fset is the file set the declarations were parsed with.
+	fset *token.FileSet
+
+	// comments are all comments of the parsed source,
+	// used to print the comments of each declaration.
+	comments []*ast.CommentGroup
+}
+
+// ConvergeToPackage processes all Go files in the given directory like
+// ConvergeFiles, but returns the merged result as a Package instead of
+// writing it. The output transformations of the converger (build tag,
+// deduplication, sorting, comment stripping and renaming) are not
+// applied, since they operate on the rendered source.
+func (c *GoFileConverger) ConvergeToPackage(ctx context.Context, dir string) (*Package, error) {
+	gf, err := c.converge(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	if gf.pkgName == "" {
+		return nil, fmt.Errorf("%w in directory: %s", ErrNoFiles, dir)
+	}
+
+	return ParsePackage(gf.source())
+}
+
+// ParsePackage parses the given Go source into a Package, e.g.
+// to inspect the output of ConvergeFiles with its output
+// transformations applied.
+func ParsePackage(src []byte) (*Package, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	pkg := Package{
+		Name:     f.Name.Name,
+		fset:     fset,
+		comments: f.Comments,
+	}
+	for _, spec := range f.Imports {
+		imp := spec.Path.Value
+		if spec.Name != nil {
+			imp = spec.Name.Name + " " + imp
+		}
+		pkg.Imports = append(pkg.Imports, imp)
+	}
+	for _, d := range f.Decls {
+		if gd, ok := d.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			continue
+		}
+		pkg.Declarations = append(pkg.Declarations, d)
+	}
+
+	return &pkg, nil
+}
+
+// DeclarationNames returns the names of the declarations of the package,
+// in order. Methods are named after their receiver type, e.g. "T.String",
+// and a declaration of several names, e.g. "var a, b int", has them all.
+func (p *Package) DeclarationNames() []string {
+	var names []string
+	for _, d := range p.Declarations {
+		if fd, ok := d.(*ast.FuncDecl); ok {
+			name, _ := declName(fd)
+			names = append(names, name)
+			continue
+		}
+		names = append(names, namesOf(d)...)
+	}
+	return names
+}
+
+// Format returns the formatted source code of the package. Comments
+// within and directly above each parsed declaration are kept, but
+// comments between declarations are dropped. Declarations added
+// programmatically are printed with their Doc comments, if any.
+func (p *Package) Format() ([]byte, error) {
+	fset := p.fset
+	if fset == nil {
+		fset = token.NewFileSet()
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("package " + p.Name + "\n")
+
+	if len(p.Imports) > 0 {
+		buf.WriteString("\nimport (\n")
+		for _, imp := range p.Imports {
+			if !strings.ContainsAny(imp, "\"`") {
+				imp = strconv.Quote(imp)
+			}
+			buf.WriteString("\t" + imp + "\n")
+		}
+		buf.WriteString(")\n")
+	}
+
+	for _, d := range p.Declarations {
+		buf.WriteString("\n")
+		node := printer.CommentedNode{Node: d, Comments: p.comments}
+		if err := printer.Fprint(&buf, fset, &node); err != nil {
+			return nil, fmt.Errorf("failed to print declaration: %w", err)
+		}
+		buf.WriteString("\n")
+	}
+
+	b, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format code: %w", err)
+	}
+
+	return b, nil
+}
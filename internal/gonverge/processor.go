@@ -2,8 +2,12 @@ package gonverge
 
 import (
 	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
-	"os"
+	"go/parser"
+	"go/token"
+	"regexp"
 	"strings"
 )
 
@@ -27,16 +31,30 @@ const (
 	// tokenImport is the token for import declarations.
 	tokenImport = `import`
 
-	// tokenImportMono is the token for a single import line.
-	tokenImportMono = tokenImport + ` "`
-
 	// tokenImportMulti is the token that starts an import block.
 	tokenImportMultiStart = tokenImport + ` (`
 
 	// tokenImportMultiEnd is the token that ends an import block.
 	tokenImportMultiFinish = `)`
+
+	// tokenDirective is the token for compiler directives,
+	// e.g. `//go:embed`, which must be kept verbatim and
+	// directly above the declaration they apply to.
+	tokenDirective = `//go:`
 )
 
+// defaultScanBufferSize is the default maximum length of a line
+// of a processed file, which is larger than the bufio.Scanner
+// default of 64KB to allow for long lines in generated files.
+const defaultScanBufferSize = 1 << 20
+
+// utf8BOM is the UTF-8 byte order mark.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// reImportMono matches a single import line, optionally with a dot,
+// blank, or named alias before the import path, e.g. `import . "fmt"`.
+var reImportMono = regexp.MustCompile(`^import\s+(?:(?:\.|[\p{L}_][\p{L}\p{N}_]*)\s+)?"`)
+
 // fileProcessor holds the *os.File representations
 // of the command line arguments.
 type fileProcessor struct {
@@ -46,43 +64,68 @@ type fileProcessor struct {
 	// state determines how the current
 	// line should be processed.
 	state procState
+
+	// pastPkgDecl is set once the package clause or an import
+	// has been processed. Any later line starting with "package "
+	// is not the package clause, but e.g. part of a raw string.
+	pastPkgDecl bool
+
+	// bufSize is the maximum length
+	// of a line of the file in bytes.
+	bufSize int
 }
 
-// newFileProcessor returns a new fileProcessor.
-func newFileProcessor(filePath string) *fileProcessor {
+// newFileProcessor returns a new fileProcessor that
+// reads lines of up to bufSize bytes.
+func newFileProcessor(filePath string, bufSize int) *fileProcessor {
 	return &fileProcessor{
 		filePath: filePath,
 		state:    procStateCoding,
+		bufSize:  bufSize,
 	}
 }
 
-// process handles opening, parsing, and aggregating
-// the contents of the file into a goFile.
-func (p *fileProcessor) process() (*goFile, error) {
-	file, err := os.Open(p.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
+// read parses and aggregates the given
+// contents of the file into a goFile.
+func (p *fileProcessor) read(src []byte) (*goFile, error) {
+	// Some editors start files with a UTF-8 byte order mark,
+	// which would otherwise end up in front of the package clause.
+	src = bytes.TrimPrefix(src, utf8BOM)
 
 	res := newGoFile()
-	scanner := bufio.NewScanner(file)
+	res.srcPath = p.filePath
+	res.sources, res.inits = declSources(p.filePath, src)
+
+	// bufio.ScanLines drops the carriage return of CRLF line
+	// endings, so files written on Windows are handled the same.
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(nil, p.bufSize)
 	for scanner.Scan() {
 		switch line := scanner.Text(); {
-		case strings.HasPrefix(line, tokenPkgDecl):
+		case !p.pastPkgDecl && strings.HasPrefix(line, tokenPkgDecl):
 			res.pkgName = strings.TrimPrefix(line, tokenPkgDecl)
 			p.state = procStateCoding
+			p.pastPkgDecl = true
+
+		case strings.HasPrefix(line, tokenDirective):
+			// Directives are appended in place, never treated as
+			// part of an import block, so that they stay attached
+			// to the declaration on the following line.
+			res.appendCode(line)
 
 		case strings.HasPrefix(line, tokenImportMultiStart):
 			p.state = procStateImporting
+			p.pastPkgDecl = true
 
-		case strings.HasPrefix(line, tokenImportMono):
-			res.addImport(strings.TrimPrefix(line, tokenImport))
+		case reImportMono.MatchString(line):
+			res.addImport(strings.TrimSpace(strings.TrimPrefix(line, tokenImport)))
+			p.pastPkgDecl = true
 
 		case p.importing() && strings.HasSuffix(line, tokenImportMultiFinish):
 			p.state = procStateCoding
 
 		case p.importing():
-			if line == "" {
+			if line = strings.TrimSpace(line); line == "" {
 				continue
 			}
 			res.addImport(line)
@@ -95,13 +138,43 @@ func (p *fileProcessor) process() (*goFile, error) {
 		}
 	}
 
-	if err = scanner.Err(); err != nil {
+	switch err := scanner.Err(); {
+	case errors.Is(err, bufio.ErrTooLong):
+		return nil, fmt.Errorf("failed to read file with a line longer than %d bytes: %w", p.bufSize, err)
+	case err != nil:
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
 	return res, nil
 }
 
+// declSources maps the names of the top-level declarations in the
+// given source to the given path, and returns the path once for each
+// init function, since those have no unique name. Only the declarations
+// that could be parsed are included, since invalid source is reported
+// later on.
+func declSources(path string, src []byte) (map[string]string, []string) {
+	sources := make(map[string]string)
+
+	f, _ := parser.ParseFile(token.NewFileSet(), path, src, parser.SkipObjectResolution)
+	if f == nil {
+		return sources, nil
+	}
+
+	var inits []string
+	for _, d := range f.Decls {
+		if _, rank := declName(d); rank == declRankInit {
+			inits = append(inits, path)
+			continue
+		}
+		for _, name := range namesOf(d) {
+			sources[name] = path
+		}
+	}
+
+	return sources, inits
+}
+
 // importing returns true if the filePath processor is currently
 // processing an import block.
 func (p *fileProcessor) importing() bool {
@@ -0,0 +1,100 @@
+package gonverge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func FuzzProcessFile(f *testing.F) {
+	seeds := []string{
+		"package main\nfunc main() {}",
+		"package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println() }",
+		"package main\n\nimport (\n\t\"fmt\"\n\t\"os\"\n)\n",
+		"package main\n\nimport _ \"embed\"\nimport . \"fmt\"\nimport f \"fmt\"\n",
+		"//go:build linux && !cgo\n// +build linux,!cgo\n\npackage main\n",
+		"package main\n\nvar s = `\nimport \"fmt\"\npackage other\n)\n`\n",
+		"package main\n\n// import \"fmt\"\n/* import (\n\t\"os\"\n) */\nfunc main() {}",
+		"package main\n\nimport (\n",
+		"import \"fmt\"\npackage main",
+		"",
+	}
+	for _, s := range seeds {
+		f.Add(s)
+	}
+
+	f.Fuzz(func(t *testing.T, src string) {
+		fp := filepath.Join(t.TempDir(), "file.go")
+		if err := os.WriteFile(fp, []byte(src), 0o644); err != nil {
+			t.Fatalf("Failed to write to temp file: %v", err)
+		}
+
+		gf, err := newProcessFunc(nil, nil, defaultScanBufferSize)(context.Background(), fp)
+		if err != nil {
+			var pathErr *os.PathError
+			if !errors.As(err, &pathErr) && !errors.Is(err, bufio.ErrTooLong) {
+				t.Fatalf("Unexpected error processing file: %v", err)
+			}
+			return
+		}
+
+		// Building the source of the processed
+		// file should never panic either.
+		_ = gf.source()
+	})
+}
+
+func TestFileProcessor_Sources(t *testing.T) {
+	a := assert.New(t)
+
+	fp := filepath.Join(t.TempDir(), "file.go")
+	src := "package main\n\nimport \"fmt\"\n\ntype T struct{}\n\nfunc (T) String() string { return \"\" }\n\n" +
+		"var (\n\ta, b = 1, 2\n\t_ = 3\n)\n\nfunc init() {}\n\nfunc main() { fmt.Println(a, b) }\n"
+	a.NoError(os.WriteFile(fp, []byte(src), 0o644))
+
+	gf, err := newProcessFunc(nil, nil, defaultScanBufferSize)(context.Background(), fp)
+	a.NoError(err)
+	a.Equal(map[string]string{
+		"T":        fp,
+		"T.String": fp,
+		"a":        fp,
+		"b":        fp,
+		"main":     fp,
+	}, gf.sources)
+}
+
+func TestFileProcessor_PackageClause(t *testing.T) {
+	tests := map[string]struct {
+		src      string
+		expected string
+	}{
+		"RawString": {
+			src:      "package main\n\nvar s = `\npackage other\n`\n",
+			expected: "package other",
+		},
+		"Comment": {
+			src:      "// Package main does things.\npackage main\n\n/*\npackage other\n*/\nfunc f() {}\n",
+			expected: "package other",
+		},
+		"AfterImport": {
+			src:      "package main\n\nimport \"fmt\"\n\nvar s = `\npackage other`\n\nfunc f() { fmt.Println(s) }\n",
+			expected: "package other`",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			gf, err := newFileProcessor("file.go", defaultScanBufferSize).read([]byte(tc.src))
+			a.NoError(err)
+			a.Equal("main", gf.pkgName)
+			a.Contains(gf.code.String(), tc.expected+"\n")
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package gonverge
+
+import "sync"
+
+// ProgressFunc is called each time a file has been processed.
+// The path is the file that was processed, done is the amount
+// of files processed so far and total is the amount of files
+// that will be processed overall.
+type ProgressFunc func(path string, done, total int)
+
+// progress keeps track of how many files have been processed
+// and reports it to a ProgressFunc. All methods are safe to call
+// on a nil progress, in which case nothing is reported.
+type progress struct {
+	// mu guards the counters and serializes
+	// calls to fn across consumers.
+	mu sync.Mutex
+
+	// fn is the function progress is reported to.
+	fn ProgressFunc
+
+	// done is the amount of files processed so far.
+	done int
+
+	// total is the amount of files to process.
+	total int
+}
+
+// newProgress returns a new progress reporting to fn,
+// or nil if there is no function to report to.
+func newProgress(fn ProgressFunc) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{fn: fn}
+}
+
+// setTotal sets the total amount of files to process.
+func (p *progress) setTotal(total int) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+}
+
+// step records that the file at the given
+// path was processed and reports it.
+func (p *progress) step(path string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.fn(path, p.done, p.total)
+}
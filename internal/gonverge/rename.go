@@ -0,0 +1,199 @@
+package gonverge
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"path"
+	"slices"
+	"strconv"
+)
+
+// renameExported adds the given prefix and suffix to the names of all
+// exported top-level functions, types, variables and constants in the
+// given Go source, and updates every reference to them in the file.
+//
+// Methods are not renamed, since that could break the interfaces their
+// types implement. References are resolved by type-checking the file,
+// so struct fields and literal keys that share a name with a renamed
+// declaration are left alone. Imports aren't resolved, which only
+// affects references to other packages, and those are never renamed.
+func renameExported(src []byte, prefix, suffix string) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	// Errors are ignored, since e.g. the imported
+	// packages are unknown to the type checker.
+	info := &types.Info{
+		Defs: make(map[*ast.Ident]types.Object),
+		Uses: make(map[*ast.Ident]types.Object),
+	}
+	cfg := types.Config{Importer: emptyImporter{}, Error: func(error) {}}
+	pkg, _ := cfg.Check(f.Name.Name, fset, []*ast.File{f}, info)
+
+	renamed := make(map[types.Object]struct{})
+	for _, name := range pkg.Scope().Names() {
+		if ast.IsExported(name) {
+			renamed[pkg.Scope().Lookup(name)] = struct{}{}
+		}
+	}
+
+	rename := func(idents map[*ast.Ident]types.Object) {
+		for ident, obj := range idents {
+			if _, ok := renamed[obj]; ok {
+				ident.Name = prefix + ident.Name + suffix
+			}
+		}
+	}
+	rename(info.Defs)
+	rename(info.Uses)
+
+	var buf bytes.Buffer
+	pcfg := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	if err = pcfg.Fprint(&buf, fset, f); err != nil {
+		return nil, fmt.Errorf("failed to print source: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renamePackage renames the package of the given Go source to the
+// given name. The merged source may reference declarations of the
+// other converged packages through their imports, e.g. "foo.Thing"
+// after converging packages foo and bar; those are now declared in
+// the same package, so the references are unqualified and the
+// imports removed.
+//
+// An import is only treated as one of the converged packages if the
+// last element of its path is one of the given package names, as is
+// the convention for Go packages, and a reference is only
+// unqualified if the merged source declares the referenced name. An
+// import is kept if any reference to it is left qualified.
+func renamePackage(src []byte, name string, pkgNames map[string]struct{}) ([]byte, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse source: %w", err)
+	}
+
+	// Errors are ignored, since e.g. the imported
+	// packages are unknown to the type checker.
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	cfg := types.Config{Importer: emptyImporter{}, Error: func(error) {}}
+	_, _ = cfg.Check(f.Name.Name, fset, []*ast.File{f}, info)
+
+	// edit replaces the source between two offsets.
+	type edit struct {
+		start, end int
+		text       string
+	}
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	edits := []edit{{start: offset(f.Name.Pos()), end: offset(f.Name.End()), text: name}}
+
+	// Find the imports of the converged packages.
+	imports := make(map[string][]edit)
+	for _, d := range f.Decls {
+		gd, ok := d.(*ast.GenDecl)
+		if !ok || gd.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			is, ok := spec.(*ast.ImportSpec)
+			if !ok {
+				continue
+			}
+			pkg, local := importName(is)
+			if _, ok = pkgNames[pkg]; !ok || local == "_" || local == "." {
+				continue
+			}
+
+			// An import without parentheses is removed
+			// entirely, since "import" alone is invalid.
+			node := ast.Node(is)
+			if !gd.Lparen.IsValid() {
+				node = gd
+			}
+			imports[local] = append(imports[local], edit{start: offset(node.Pos()), end: offset(node.End())})
+		}
+	}
+
+	// Unqualify the references to the declarations of the converged
+	// packages, but not of local variables that shadow their names.
+	declared := make(map[string]struct{})
+	for _, n := range declNames(f) {
+		declared[n] = struct{}{}
+	}
+	kept := make(map[string]struct{})
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		x, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if _, ok = info.Uses[x].(*types.PkgName); !ok {
+			return true
+		}
+		if _, ok = imports[x.Name]; !ok {
+			return true
+		}
+		if _, ok = declared[sel.Sel.Name]; !ok {
+			kept[x.Name] = struct{}{}
+			return true
+		}
+		edits = append(edits, edit{start: offset(x.Pos()), end: offset(sel.Sel.Pos())})
+		return true
+	})
+	for local, importEdits := range imports {
+		if _, ok := kept[local]; !ok {
+			edits = append(edits, importEdits...)
+		}
+	}
+
+	// Apply the edits from the end, so the
+	// offsets of earlier edits stay valid.
+	slices.SortFunc(edits, func(a, b edit) int { return cmp.Compare(b.start, a.start) })
+	out := slices.Clone(src)
+	for _, e := range edits {
+		out = slices.Concat(out[:e.start], []byte(e.text), out[e.end:])
+	}
+
+	return out, nil
+}
+
+// importName returns the last element of the path of the given
+// import, which is conventionally the name of the package, and
+// the name the import is referred to by in the file.
+func importName(is *ast.ImportSpec) (string, string) {
+	p, err := strconv.Unquote(is.Path.Value)
+	if err != nil {
+		return "", ""
+	}
+	pkg := path.Base(p)
+	if is.Name != nil {
+		return pkg, is.Name.Name
+	}
+	return pkg, pkg
+}
+
+// emptyImporter is a types.Importer that imports every package as an
+// empty package named after the last element of its path, so a file
+// can be type-checked without resolving its imports.
+type emptyImporter struct{}
+
+// Import returns an empty, complete package with the given path.
+func (emptyImporter) Import(p string) (*types.Package, error) {
+	pkg := types.NewPackage(p, path.Base(p))
+	pkg.MarkComplete()
+	return pkg, nil
+}
@@ -0,0 +1,130 @@
+package gonverge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// ErrValidation is returned by Validate when the files
+// in a directory can't be converged into a valid Go file.
+var ErrValidation = errors.New("validation failed")
+
+// Validate checks that all Go files in the given directory can be
+// converged into a single valid Go file without writing any output.
+// It checks that all files declare the same package, that the merged
+// source parses, and that no top-level declaration is duplicated,
+// unless duplicates are removed with WithDeduplication. Different
+// package names are allowed if the output package name is set with
+// WithOutputPackageName.
+//
+// Each issue found is reported as a separate error joined
+// together with ErrValidation, so they can be unwrapped.
+func (c *GoFileConverger) Validate(ctx context.Context, dir string) error {
+	gf, err := c.converge(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	var issues []error
+	if len(gf.pkgNames) > 1 && c.outputPkgName == "" {
+		names := slices.Sorted(maps.Keys(gf.pkgNames))
+		issues = append(issues, fmt.Errorf("inconsistent package names: %s",
+			strings.Join(names, ", ")))
+	}
+
+	f, err := parser.ParseFile(token.NewFileSet(), "", gf.source(), parser.AllErrors)
+	if err != nil {
+		var errList scanner.ErrorList
+		if !errors.As(err, &errList) {
+			return fmt.Errorf("failed to parse source: %w", err)
+		}
+		for _, e := range errList {
+			issues = append(issues, fmt.Errorf("syntax error: %w", e))
+		}
+	}
+	if f != nil && !c.dedup {
+		issues = append(issues, duplicateDecls(f, gf.duplicates)...)
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+
+	return errors.Join(append([]error{ErrValidation}, issues...)...)
+}
+
+// duplicateDecls returns an error for each top-level declaration
+// in the given file that has the same name as a previous one. The
+// error names the source files of the declaration, if they are known.
+func duplicateDecls(f *ast.File, sources map[string][]string) []error {
+	var (
+		errs []error
+		seen = make(map[string]struct{})
+	)
+	for _, name := range declNames(f) {
+		if _, ok := seen[name]; ok {
+			if paths := sources[name]; len(paths) > 0 {
+				errs = append(errs, fmt.Errorf("duplicate declaration: %s (declared in %s)",
+					name, strings.Join(paths, ", ")))
+				continue
+			}
+			errs = append(errs, fmt.Errorf("duplicate declaration: %s", name))
+			continue
+		}
+		seen[name] = struct{}{}
+	}
+
+	return errs
+}
+
+// declNames returns the names of all top-level declarations in the
+// given file, excluding init functions and blank identifiers which
+// may legally be declared more than once.
+func declNames(f *ast.File) []string {
+	var names []string
+	for _, d := range f.Decls {
+		names = append(names, namesOf(d)...)
+	}
+
+	return names
+}
+
+// namesOf returns the names declared by the given top-level
+// declaration, excluding init functions and blank identifiers.
+func namesOf(d ast.Decl) []string {
+	var names []string
+	add := func(name string) {
+		if name != "_" {
+			names = append(names, name)
+		}
+	}
+
+	switch d := d.(type) {
+	case *ast.FuncDecl:
+		name, rank := declName(d)
+		if rank != declRankInit {
+			add(name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				add(s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					add(n.Name)
+				}
+			}
+		}
+	}
+
+	return names
+}
@@ -2,20 +2,69 @@ package gonverge
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"go/parser"
+	"go/token"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
+
+	"golang.org/x/sync/semaphore"
 )
 
+// walkOptions determine which files
+// a fileProducer walks and produces.
+type walkOptions struct {
+	// excludes is a map of regular expressions
+	// to apply to file names for exclusion.
+	excludes map[string]*regexp.Regexp
+
+	// excludeDirs is a map of regular expressions to
+	// apply to subdirectory names for exclusion.
+	excludeDirs map[string]*regexp.Regexp
+
+	// pkgSet is the set of package names to include.
+	pkgSet map[string]struct{}
+
+	// excludeTests excludes test files.
+	excludeTests bool
+
+	// maxDepth is how many levels of subdirectories to walk.
+	// Zero only walks the root directory, and a negative
+	// value walks all subdirectories.
+	maxDepth int
+
+	// followSymlinks follows symbolic links
+	// to directories when walking.
+	followSymlinks bool
+
+	// minSize is the minimum size of files in bytes.
+	minSize int64
+
+	// maxSize is the maximum size of files in
+	// bytes, where zero means no maximum.
+	maxSize int64
+
+	// maxFiles is the maximum amount of files to
+	// produce, where zero means no maximum.
+	maxFiles int
+
+	// srcFiles are the exact files to produce instead
+	// of walking the directory, if there are any.
+	srcFiles []string
+}
+
 // fileProducer walks a directory and sends all file paths
 // to the given channel for the consumer to process.
 type fileProducer struct {
-	// excludes is a map of regular expressions
-	// to apply to file names for exclusion.
-	excludes map[string]regexp.Regexp
+	walkOptions
+
+	// progress tracks the total amount of files to process.
+	progress *progress
 
 	// lg is the lg to use for logging.
 	lg debugLogger
@@ -23,154 +72,507 @@ type fileProducer struct {
 	// fpCh is the channel to send file paths to.
 	fpCh chan<- string
 
-	// errCh is the channel to send errors to.
-	errCh chan<- error
+	// skipped are the paths of the Go files
+	// that were walked but are not valid.
+	skipped []string
 }
 
 // newFileProducer handles the creation of a new fileProducer.
-func newFileProducer(lg debugLogger, ex map[string]regexp.Regexp, fc chan<- string, ec chan<- error) *fileProducer {
+func newFileProducer(lg debugLogger, opts walkOptions, prog *progress, fc chan<- string) *fileProducer {
 	return &fileProducer{
-		lg:       lg,
-		fpCh:     fc,
-		errCh:    ec,
-		excludes: ex,
+		walkOptions: opts,
+		lg:          lg,
+		fpCh:        fc,
+		progress:    prog,
 	}
 }
 
 // produce walks the given directory and sends all file paths
 // to the fpCh channel for the consumer to process.
-func (fp *fileProducer) produce(dir string) {
-	lg := fp.lg.WithName("walkDir")
+//
+// The directory is fully scanned before any file paths are sent
+// so that the total amount of files is known up front, which is
+// needed for progress reporting. It stops producing file paths
+// if the context is cancelled.
+func (fp *fileProducer) produce(ctx context.Context, dir string) error {
+	lg := fp.lg.WithName("produce")
 	lg.Debug("Producing files in directory:", dir)
 
-	if err := fp.walkDir(dir); err != nil {
-		fp.errCh <- fmt.Errorf("error walking directory: %w", err)
+	paths, err := fp.walkDir(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("error walking directory: %w", err)
+	}
+
+	if len(paths) == 0 {
+		lg.Debugf("No files to produce in directory: %s", dir)
+	}
+
+	fp.progress.setTotal(len(paths))
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			return ctx.Err() //nolint:wrapcheck // Context errors don't need wrapped.
+		case fp.fpCh <- path:
+		}
 	}
+
+	return nil
 }
 
-// walkDir walks the given directory and sends all file paths
-// to the fpCh channel for the consumer to process.
-func (fp *fileProducer) walkDir(dir string) error {
+// walkDir walks the given directory and returns the
+// paths of all files that are valid for processing.
+func (fp *fileProducer) walkDir(ctx context.Context, dir string) ([]string, error) {
+	if len(fp.srcFiles) > 0 {
+		return fp.statSrcFiles()
+	}
+	if fp.followSymlinks {
+		return fp.walkDirFollow(ctx, dir)
+	}
+
 	lg := fp.lg.WithName("walkDir")
 	lg.Debug("Walking directory:", dir)
 
-	return fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error { //nolint:wrapcheck // Low level error doesn't need wrapped any further.
+	var paths []string
+	err := fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return fmt.Errorf("error walking directory: %w", err)
 		}
+		if err = ctx.Err(); err != nil {
+			return err //nolint:wrapcheck // Context errors don't need wrapped.
+		}
 		if d.IsDir() {
+			if path == "." {
+				return nil
+			}
+			if fp.skipSubdir(lg, path) {
+				return fs.SkipDir
+			}
+			lg.Debugf("walking subdirectory: %s", path)
 			return nil
 		}
 
+		fullPath := filepath.Join(dir, path)
+		if d.Type()&fs.ModeSymlink != 0 {
+			// A dangling symbolic link, e.g. an editor's
+			// lock file, can't be read, so it's skipped.
+			if _, err = os.Stat(fullPath); errors.Is(err, fs.ErrNotExist) {
+				lg.Debugf("skipping broken symlink: %s", fullPath)
+				return nil
+			}
+		}
+
 		info, err := d.Info()
 		if err != nil {
 			return fmt.Errorf("error getting file info: %w", err)
 		}
 
-		fullPath := filepath.Join(dir, path)
-		if !fp.validFile(info.Name(), fullPath) {
-			lg.Debug("file path is not valid:", fullPath)
+		if fp.visitFile(lg, info, fullPath) {
+			paths = append(paths, fullPath)
+		}
+
+		return fp.checkMaxFiles(len(paths))
+	})
+
+	return paths, err //nolint:wrapcheck // Low level error doesn't need wrapped any further.
+}
+
+// statSrcFiles returns the source files to produce instead of
+// walking a directory, after checking that they are all files.
+func (fp *fileProducer) statSrcFiles() ([]string, error) {
+	fp.lg.Debugf("Using %d source files instead of walking directory", len(fp.srcFiles))
+	if err := fp.checkMaxFiles(len(fp.srcFiles)); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(fp.srcFiles))
+	for _, path := range fp.srcFiles {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error getting file info: %w", err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("source file %s is a directory", path)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// walkDirFollow is like walkDir, but follows symbolic links to
+// directories. The real path of every walked directory is tracked,
+// so symbolic links that form a cycle are only walked once.
+func (fp *fileProducer) walkDirFollow(ctx context.Context, dir string) ([]string, error) {
+	lg := fp.lg.WithName("walkDirFollow")
+	lg.Debug("Walking directory following symlinks:", dir)
+
+	var (
+		paths   []string
+		visited = make(map[string]struct{})
+	)
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		if err := ctx.Err(); err != nil {
+			return err //nolint:wrapcheck // Context errors don't need wrapped.
+		}
+
+		fullDir := filepath.Join(dir, filepath.FromSlash(path))
+		realDir, err := filepath.EvalSymlinks(fullDir)
+		if err != nil {
+			return fmt.Errorf("error resolving directory %s: %w", fullDir, err)
+		}
+		if _, ok := visited[realDir]; ok {
+			lg.Debugf("skipping already walked directory (symlink cycle): %s", path)
 			return nil
 		}
+		visited[realDir] = struct{}{}
+
+		entries, err := os.ReadDir(fullDir)
+		if err != nil {
+			return fmt.Errorf("error walking directory: %w", err)
+		}
 
-		lg.Debug("file path is valid:", fullPath)
-		fp.fpCh <- fullPath
+		for _, e := range entries {
+			entryPath := e.Name()
+			if path != "." {
+				entryPath = path + "/" + e.Name()
+			}
+			fullPath := filepath.Join(dir, filepath.FromSlash(entryPath))
+
+			// Only symbolic links are stat'ed, since stat follows
+			// them, unlike the entry's type. A dangling one, e.g.
+			// an editor's lock file, is skipped.
+			info, err := e.Info()
+			if err == nil && e.Type()&fs.ModeSymlink != 0 {
+				info, err = os.Stat(fullPath)
+				if errors.Is(err, fs.ErrNotExist) {
+					lg.Debugf("skipping broken symlink: %s", fullPath)
+					continue
+				}
+			}
+			if err != nil {
+				return fmt.Errorf("error getting file info: %w", err)
+			}
+			if !info.IsDir() {
+				if fp.visitFile(lg, info, fullPath) {
+					paths = append(paths, fullPath)
+				}
+				if err = fp.checkMaxFiles(len(paths)); err != nil {
+					return err
+				}
+				continue
+			}
+			if fp.skipSubdir(lg, entryPath) {
+				continue
+			}
+			lg.Debugf("walking subdirectory: %s", entryPath)
+			if err = walk(entryPath); err != nil {
+				return err
+			}
+		}
 
 		return nil
-	})
+	}
+
+	if err := walk("."); err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// checkMaxFiles returns ErrTooManyFiles if the given
+// amount of files exceeds the maximum, if there is one.
+func (fp *fileProducer) checkMaxFiles(n int) error {
+	if fp.maxFiles > 0 && n > fp.maxFiles {
+		return fmt.Errorf("%w: more than %d files to converge", ErrTooManyFiles, fp.maxFiles)
+	}
+	return nil
+}
+
+// skipSubdir returns true, logging why, if the given subdirectory
+// relative to the root directory should not be walked.
+func (fp *fileProducer) skipSubdir(lg debugLogger, path string) bool {
+	name := filepath.Base(path)
+	for _, re := range fp.excludeDirs {
+		if re.MatchString(name) {
+			lg.Debugf("skipping excluded subdirectory: %s", path)
+			return true
+		}
+	}
+
+	if fp.walkSubdir(path) {
+		return false
+	}
+
+	if fp.maxDepth == 0 {
+		lg.Debugf("skipping subdirectory (non-recursive): %s", path)
+	} else {
+		lg.Debugf("skipping subdirectory (max depth %d): %s", fp.maxDepth, path)
+	}
+
+	return true
+}
+
+// visitFile returns true, logging the result,
+// if the given file is valid for processing.
+func (fp *fileProducer) visitFile(lg debugLogger, info fs.FileInfo, fullPath string) bool {
+	if !fp.validFile(info, fullPath) {
+		lg.Debug("file path is not valid:", fullPath)
+		if strings.HasSuffix(info.Name(), ".go") {
+			fp.skipped = append(fp.skipped, fullPath)
+		}
+		return false
+	}
+
+	lg.Debug("file path is valid:", fullPath)
+	return true
+}
+
+// walkSubdir returns true if the given subdirectory,
+// relative to the root directory, should be walked.
+func (fp *fileProducer) walkSubdir(path string) bool {
+	if fp.maxDepth < 0 {
+		return true
+	}
+	depth := strings.Count(path, "/") + 1
+	return depth <= fp.maxDepth
 }
 
 // validFile checks that the file is a valid *non-test* Go file.
 // If the pkgSet is empty, it will default to the top-level
-// Go files that are *not* test files.
+// Go files that are *not* test files, unless test files are
+// not excluded.
 //
 // However, if the pkgSet is not empty, it will include all
 // files that have a package name that is in the set.
 // This behavior essentially allows for the user to specify
 // the package names they want to include, including test files
-// with the package name in the set.
-func (fp *fileProducer) validFile(name, fullPath string) bool {
+// with the package name in the set, unless they're excluded.
+func (fp *fileProducer) validFile(info fs.FileInfo, fullPath string) bool {
+	name := info.Name()
 	lg := fp.lg.WithName("validFile")
 	lg.Debugf("Validating package %s at: %s", name, fullPath)
 
 	if !strings.HasSuffix(name, ".go") {
+		lg.Debugf("skipping non-Go file: %s", fullPath)
 		return false
 	}
 
 	// Check if the file should be excluded from processing.
 	for _, re := range fp.excludes {
 		if re.MatchString(name) {
-			lg.Debug("File excluded from processing:", name)
+			lg.Debugf("excluding file: %s (matched by %s)", fullPath, re.String())
 			return false
 		}
 	}
 
+	if fp.excludeTests && strings.HasSuffix(name, "_test.go") {
+		lg.Debugf("excluding test file: %s", fullPath)
+		return false
+	}
+
+	// Check the file size is within the configured limits.
+	if size := info.Size(); size < fp.minSize || (fp.maxSize > 0 && size > fp.maxSize) {
+		lg.Debugf("File %s excluded by size (%d bytes)", name, size)
+		return false
+	}
+
+	if len(fp.pkgSet) == 0 {
+		return true
+	}
+
+	// Only parse the package clause, the rest of
+	// the file is handled by the fileProcessor.
+	f, err := parser.ParseFile(token.NewFileSet(), fullPath, nil, parser.PackageClauseOnly)
+	if err != nil {
+		lg.Debugf("Failed to parse package clause of %s: %v", fullPath, err)
+		return false
+	}
+	if _, ok := fp.pkgSet[f.Name.Name]; !ok {
+		lg.Debugf("File %s excluded by package %s", name, f.Name.Name)
+		return false
+	}
+
 	return true
 }
 
+// errFileTimeout is returned when processing
+// a file takes longer than the worker timeout.
+var errFileTimeout = errors.New("timed out processing file")
+
+// processFunc processes the file at the given path into a goFile.
+type processFunc func(ctx context.Context, path string) (*goFile, error)
+
+// newProcessFunc returns a processFunc that reads each file and
+// processes it with a fileProcessor. If the semaphore is not nil,
+// it limits how many files are read at the same time, and if the
+// hook is not nil, it is run on the contents of each file first.
+// Files may have lines of up to bufSize bytes.
+func newProcessFunc(sem *semaphore.Weighted, hook PreProcessFunc, bufSize int) processFunc {
+	return func(ctx context.Context, path string) (*goFile, error) {
+		src, err := readFile(ctx, sem, path)
+		if err != nil {
+			return nil, err
+		}
+		if hook != nil {
+			if src, err = hook(path, src); err != nil {
+				return nil, fmt.Errorf("failed to run pre-process hook: %w", err)
+			}
+		}
+		return newFileProcessor(path, bufSize).read(src)
+	}
+}
+
+// readFile reads the file at the given path. If the semaphore is
+// not nil, it is acquired before the file is opened and released
+// once the file has been read and closed.
+func readFile(ctx context.Context, sem *semaphore.Weighted, path string) ([]byte, error) {
+	if sem != nil {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			return nil, err //nolint:wrapcheck // Context errors don't need wrapped.
+		}
+		defer sem.Release(1)
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return src, nil
+}
+
 // fileConsumer reads file paths from the given channel,
-// processes them, and then sends back either the processed
-// result or an error (if one occurred).
+// processes them, and then sends back the processed result.
 type fileConsumer struct {
+	// lg is the logger to use for logging.
+	lg debugLogger
+
 	// fpCh is the channel to read file paths from.
 	fpCh <-chan string
 
 	// resCh is the channel to send processed files to.
 	resCh chan<- *goFile
 
-	// errCh is the channel to send errors to.
-	errCh chan error
+	// progress is notified each time a file is processed.
+	progress *progress
+
+	// process processes a single file.
+	process processFunc
+
+	// timeout limits how long processing a single
+	// file may take, where zero means no limit.
+	timeout time.Duration
+
+	// skipTimedOut skips the files that time out with
+	// a warning, instead of failing once all are done.
+	skipTimedOut bool
 }
 
 // newFileConsumer returns a new fileConsumer.
-func newFileConsumer(fc <-chan string, rc chan<- *goFile, ec chan error) *fileConsumer {
+func newFileConsumer(
+	lg debugLogger, fc <-chan string, rc chan<- *goFile, prog *progress, proc processFunc,
+	timeout time.Duration, skipTimedOut bool,
+) *fileConsumer {
 	return &fileConsumer{
-		fpCh:  fc,
-		resCh: rc,
-		errCh: ec,
+		lg:           lg,
+		fpCh:         fc,
+		resCh:        rc,
+		progress:     prog,
+		process:      proc,
+		timeout:      timeout,
+		skipTimedOut: skipTimedOut,
 	}
 }
 
 // consume consumes file paths from the given channel,
-// processes them, and then sends back either the processed
-// result or an error (if one occurred).
+// processes them, and then sends back the processed result.
 //
-// It will stop processing if an error occurs or if the
-// context is cancelled, since this is an all or nothing
-// command (can't *half* converge files).
-func (fc *fileConsumer) consume(ctx context.Context) {
+// It will stop processing and return the error if one occurs
+// or if the context is cancelled, since this is an all or nothing
+// command (can't *half* converge files). The exception are files
+// that time out: those errors are collected and returned once all
+// other files have been processed, so one slow file doesn't hide
+// the others, or skipped with a warning if that is configured.
+func (fc *fileConsumer) consume(ctx context.Context) error {
+	lg := fc.lg.WithName("consume")
+
+	var timeoutErrs error
 	for {
 		select {
 		case <-ctx.Done():
-			return
-		case <-fc.errCh:
-			return
+			return ctx.Err() //nolint:wrapcheck // Context errors don't need wrapped.
 		case fp, ok := <-fc.fpCh:
 			if !ok {
-				return
+				return timeoutErrs
+			}
+			lg.Debugf("Processing file: %s", fp)
+			res, err := fc.processFile(ctx, fp)
+			if errors.Is(err, errFileTimeout) && fc.skipTimedOut {
+				lg.Warnf("Skipping file that timed out: %v", err)
+				fc.progress.step(fp)
+				continue
+			}
+			if errors.Is(err, errFileTimeout) {
+				lg.Debugf("Timed out processing file: %s", fp)
+				timeoutErrs = errors.Join(timeoutErrs, err)
+				continue
 			}
-			res, err := fc.processFile(fp)
 			if err != nil {
-				fc.errCh <- err
-				return
+				return err
+			}
+			fc.progress.step(fp)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err() //nolint:wrapcheck // Context errors don't need wrapped.
+			case fc.resCh <- res:
 			}
-			fc.resCh <- res
 		}
 	}
 }
 
 // processFile processes the given file path and returns the
-// processed result or an error if one occurred.
-func (fc *fileConsumer) processFile(fp string) (*goFile, error) {
-	proc := newFileProcessor(fp)
-	if proc == nil {
-		return nil, fmt.Errorf("failed to create fileProcessor for file: %s", fp)
+// processed result or an error if one occurred. If a timeout
+// is set and processing the file takes longer, errFileTimeout
+// is returned without waiting for processing to finish.
+func (fc *fileConsumer) processFile(ctx context.Context, fp string) (*goFile, error) {
+	if fc.timeout <= 0 {
+		res, err := fc.process(ctx, fp)
+		if err != nil {
+			return nil, fmt.Errorf("error processing file: %w", err)
+		}
+		return res, nil
 	}
 
-	res, err := proc.process()
-	if err != nil {
-		return nil, fmt.Errorf("error processing file: %w", err)
+	tctx, cancel := context.WithTimeout(ctx, fc.timeout)
+	defer cancel()
+
+	// Processing isn't context aware, so it runs in its own
+	// goroutine; the channel is buffered so the goroutine can
+	// always finish, even if nobody is waiting for it anymore.
+	type result struct {
+		res *goFile
+		err error
 	}
+	done := make(chan result, 1)
+	go func() {
+		res, err := fc.process(tctx, fp)
+		done <- result{res: res, err: err}
+	}()
 
-	return res, nil
+	select {
+	case <-tctx.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err //nolint:wrapcheck // Context errors don't need wrapped.
+		}
+		return nil, fmt.Errorf("%w %s after %s", errFileTimeout, fp, fc.timeout)
+	case r := <-done:
+		if r.err != nil {
+			return nil, fmt.Errorf("error processing file: %w", r.err)
+		}
+		return r.res, nil
+	}
 }
@@ -0,0 +1,131 @@
+package gonverge
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/semaphore"
+
+	"github.com/dannyhinshaw/converge/internal/olog"
+)
+
+func TestFileConsumer_Timeout(t *testing.T) {
+	a := assert.New(t)
+
+	// The processor blocks on slow.go until the test is done,
+	// like a file on an unresponsive network filesystem would.
+	release := make(chan struct{})
+	defer close(release)
+
+	fpCh := make(chan string, 2)
+	fpCh <- "slow.go"
+	fpCh <- "fast.go"
+	close(fpCh)
+
+	resCh := make(chan *goFile, 2)
+	consumer := newFileConsumer(olog.NewNoopLogger(), fpCh, resCh, nil, func(_ context.Context, path string) (*goFile, error) {
+		if path == "slow.go" {
+			<-release
+		}
+		gf := newGoFile()
+		gf.srcPath = path
+		return gf, nil
+	}, 50*time.Millisecond, false)
+
+	start := time.Now()
+	err := consumer.consume(context.Background())
+	a.ErrorIs(err, errFileTimeout)
+	a.ErrorContains(err, "slow.go")
+	a.Less(time.Since(start), time.Second)
+
+	// The fast file is still processed after the slow one timed out.
+	close(resCh)
+	var paths []string
+	for gf := range resCh {
+		paths = append(paths, gf.srcPath)
+	}
+	a.Equal([]string{"fast.go"}, paths)
+}
+
+func TestFileConsumer_SkipTimedOut(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	fpCh := make(chan string, 2)
+	fpCh <- "slow.go"
+	fpCh <- "fast.go"
+	close(fpCh)
+
+	var logs bytes.Buffer
+	lg := olog.NewLogger(olog.LevelWarn, olog.WithWriter(&logs))
+	resCh := make(chan *goFile, 2)
+	consumer := newFileConsumer(lg, fpCh, resCh, nil, func(_ context.Context, path string) (*goFile, error) {
+		if path == "slow.go" {
+			<-release
+		}
+		gf := newGoFile()
+		gf.srcPath = path
+		return gf, nil
+	}, 50*time.Millisecond, true)
+
+	a.NoError(consumer.consume(context.Background()))
+	a.Contains(logs.String(), "Skipping file that timed out: timed out processing file slow.go after 50ms")
+
+	close(resCh)
+	var paths []string
+	for gf := range resCh {
+		paths = append(paths, gf.srcPath)
+	}
+	a.Equal([]string{"fast.go"}, paths)
+}
+
+func TestFileConsumer_TimeoutCancelled(t *testing.T) {
+	a := assert.New(t)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	fpCh := make(chan string, 1)
+	fpCh <- "slow.go"
+	close(fpCh)
+
+	consumer := newFileConsumer(olog.NewNoopLogger(), fpCh, make(chan *goFile), nil, func(context.Context, string) (*goFile, error) {
+		<-release
+		return newGoFile(), nil
+	}, time.Minute, false)
+
+	// Cancelling the parent context is not a timeout of the file.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := consumer.consume(ctx)
+	a.ErrorIs(err, context.DeadlineExceeded)
+	a.NotErrorIs(err, errFileTimeout)
+}
+
+func TestReadFile_Semaphore(t *testing.T) {
+	a := assert.New(t)
+
+	fp := filepath.Join(t.TempDir(), "file.go")
+	a.NoError(os.WriteFile(fp, []byte("package main\n"), 0o644))
+
+	sem := semaphore.NewWeighted(1)
+	src, err := readFile(context.Background(), sem, fp)
+	a.NoError(err)
+	a.Equal("package main\n", string(src))
+
+	// The semaphore is released after reading, so it can be acquired
+	// again, and reading waits while it is held by someone else.
+	a.True(sem.TryAcquire(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = readFile(ctx, sem, fp)
+	a.ErrorIs(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,67 @@
+package olog
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// contextKey is a context key registered with
+// RegisterContextKey, along with its log field.
+type contextKey struct {
+	// key is the key of the value in the context.
+	key any
+
+	// field is the name the value is logged with.
+	field string
+}
+
+var (
+	// contextKeysMu guards contextKeys.
+	contextKeysMu sync.RWMutex
+
+	// contextKeys are the registered context
+	// keys, in the order they were registered.
+	contextKeys []contextKey
+)
+
+// RegisterContextKey registers a context key, e.g. that of a request
+// ID, whose value loggers created with WithContext include in every log
+// message as the given log field. Registering a key again replaces its
+// log field. It is usually called once, when the program starts.
+func RegisterContextKey(key any, logField string) {
+	contextKeysMu.Lock()
+	defer contextKeysMu.Unlock()
+
+	for i, ck := range contextKeys {
+		if ck.key == key {
+			contextKeys[i].field = logField
+			return
+		}
+	}
+	contextKeys = append(contextKeys, contextKey{key: key, field: logField})
+}
+
+// field is a log field and its value.
+type field struct {
+	// name is the name of the field.
+	name string
+
+	// value is the formatted value of the field.
+	value string
+}
+
+// contextFields returns the log fields of the values of
+// the registered context keys found in the given context.
+func contextFields(ctx context.Context) []field {
+	contextKeysMu.RLock()
+	defer contextKeysMu.RUnlock()
+
+	var fields []field
+	for _, ck := range contextKeys {
+		if v := ctx.Value(ck.key); v != nil {
+			fields = append(fields, field{name: ck.field, value: fmt.Sprint(v)})
+		}
+	}
+	return fields
+}
@@ -1,5 +1,10 @@
 package olog
 
+import (
+	"context"
+	"os"
+)
+
 // NoopLogger implements the LevelLogger interface but discards all log messages.
 type NoopLogger struct{}
 
@@ -20,13 +25,36 @@ func (NoopLogger) Infof(string, ...any) {}
 // Info does nothing.
 func (NoopLogger) Info(...any) {}
 
+// Warnf does nothing.
+func (NoopLogger) Warnf(string, ...any) {}
+
+// Warn does nothing.
+func (NoopLogger) Warn(...any) {}
+
 // Errorf does nothing.
 func (NoopLogger) Errorf(string, ...any) {}
 
 // Error does nothing.
 func (NoopLogger) Error(...any) {}
 
+// Fatalf exits the program with status 1. Nothing is logged,
+// but the error is still fatal, so the program must stop.
+func (NoopLogger) Fatalf(string, ...any) {
+	os.Exit(1)
+}
+
+// Fatal exits the program with status 1. Nothing is logged,
+// but the error is still fatal, so the program must stop.
+func (NoopLogger) Fatal(...any) {
+	os.Exit(1)
+}
+
 // WithName returns the NoopLogger, unaltered.
 func (l NoopLogger) WithName(string) LevelLogger {
 	return l
 }
+
+// WithContext returns the NoopLogger, unaltered.
+func (l NoopLogger) WithContext(context.Context) LevelLogger {
+	return l
+}
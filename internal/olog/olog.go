@@ -5,10 +5,14 @@
 package olog
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"time"
 )
 
 // Level represents the logging level, which determines
@@ -22,6 +26,9 @@ const (
 	// LevelInfo is for info messages.
 	LevelInfo
 
+	// LevelWarn is for warning messages.
+	LevelWarn
+
 	// LevelError is for error messages.
 	LevelError
 )
@@ -44,6 +51,10 @@ const (
 	// It is padded with a space to match the length of "error".
 	infoLevel levelName = "info "
 
+	// warnLevel is the string representation of the warn level.
+	// It is padded with a space to match the length of "error".
+	warnLevel levelName = "warn "
+
 	// errorLevel is the string representation of the error level.
 	errorLevel levelName = "error"
 )
@@ -56,6 +67,8 @@ func (l Level) String() string {
 		return debugLevel
 	case LevelInfo:
 		return infoLevel
+	case LevelWarn:
+		return warnLevel
 	case LevelError:
 		return errorLevel
 	default:
@@ -77,15 +90,32 @@ type LevelLogger interface {
 	// Info logs an info message.
 	Info(v ...any)
 
+	// Warnf logs a formatted warning message.
+	Warnf(format string, v ...any)
+
+	// Warn logs a warning message.
+	Warn(v ...any)
+
 	// Errorf logs a formatted error message.
 	Errorf(format string, v ...any)
 
 	// Error logs an error message.
 	Error(v ...any)
 
+	// Fatalf logs a formatted error message and exits with status 1.
+	Fatalf(format string, v ...any)
+
+	// Fatal logs an error message and exits with status 1.
+	Fatal(v ...any)
+
 	// WithName returns a new logger instance with a specific
 	// name prefix applied to all log messages.
 	WithName(name string) LevelLogger
+
+	// WithContext returns a new logger instance that includes
+	// the values of the registered context keys found in the
+	// given context in all log messages.
+	WithContext(ctx context.Context) LevelLogger
 }
 
 // Logger is a simple logger that can be used to log messages at different levels.
@@ -101,6 +131,18 @@ type Logger struct {
 
 	// callDepth specifies the stack depth for file/line reporting.
 	callDepth int
+
+	// json formats log messages as JSON objects
+	// instead of human-readable text.
+	json bool
+
+	// fileInfo includes the file and line of the
+	// caller in log messages, whatever the level.
+	fileInfo bool
+
+	// fields are the log fields from the context
+	// included in all log messages, if any.
+	fields []field
 }
 
 // NewLogger creates a new Logger.
@@ -113,7 +155,8 @@ func NewLogger(lvl Level, opts ...Option) Logger {
 	lg := Logger{
 		logger:    log.New(os.Stderr, "", flags),
 		level:     lvl,
-		callDepth: 3, //nolint:mnd // 3 is the call depth to log from.
+		callDepth: 4, //nolint:mnd // 4 is the call depth to log from.
+		fileInfo:  lvl == LevelDebug,
 	}
 
 	for _, opt := range opts {
@@ -133,6 +176,34 @@ func WithWriter(w io.Writer) Option {
 	}
 }
 
+// WithJSON returns an Option that formats each log message as a JSON
+// object, which makes the logs machine-parseable (e.g. in CI pipelines).
+func WithJSON(enabled bool) Option {
+	return func(l *Logger) {
+		l.json = enabled
+		if enabled {
+			// File and line prefixes would
+			// make the output invalid JSON.
+			l.logger.SetFlags(0)
+		}
+	}
+}
+
+// WithFileInfo returns an Option that includes the file and line of
+// the caller in every log message, not just at LevelDebug. This helps
+// to find where an error was logged from without verbose logging.
+func WithFileInfo(enabled bool) Option {
+	return func(l *Logger) {
+		if !enabled {
+			return
+		}
+		l.fileInfo = true
+		if !l.json {
+			l.logger.SetFlags(log.Lshortfile)
+		}
+	}
+}
+
 // Debugf logs a formatted debug message if the logger is set to LevelDebug.
 // It will not output anything if the logger level is higher than LevelDebug.
 func (l Logger) Debugf(format string, v ...any) {
@@ -165,6 +236,22 @@ func (l Logger) Info(v ...any) {
 	}
 }
 
+// Warnf logs a formatted warning message if the logger is set to LevelWarn or lower.
+// It will not output anything if the logger level is higher than LevelWarn.
+func (l Logger) Warnf(format string, v ...any) {
+	if l.level <= LevelWarn {
+		l.logf(LevelWarn, format, v...)
+	}
+}
+
+// Warn logs a warning message if the logger is set to LevelWarn or lower.
+// It will not output anything if the logger level is higher than LevelWarn.
+func (l Logger) Warn(v ...any) {
+	if l.level <= LevelWarn {
+		l.log(LevelWarn, v...)
+	}
+}
+
 // Errorf logs a formatted error message.
 func (l Logger) Errorf(format string, v ...any) {
 	l.logf(LevelError, format, v...)
@@ -175,26 +262,70 @@ func (l Logger) Error(v ...any) {
 	l.log(LevelError, v...)
 }
 
-// WithName returns a new logger with the given name.
+// Fatalf logs a formatted error message and then exits the program
+// with status 1. Deferred functions are not run, so it should only be
+// used for unrecoverable errors, e.g. when setting up the program.
+func (l Logger) Fatalf(format string, v ...any) {
+	l.logf(LevelError, format, v...)
+	os.Exit(1)
+}
+
+// Fatal logs an error message and then exits the program with
+// status 1. Deferred functions are not run, so it should only be
+// used for unrecoverable errors, e.g. when setting up the program.
+func (l Logger) Fatal(v ...any) {
+	l.log(LevelError, v...)
+	os.Exit(1)
+}
+
+// WithName returns a new logger with the given name. If the logger
+// already has a name, the names are joined with a "/" so that log
+// messages show the full hierarchy (e.g. "parent/child").
 func (l Logger) WithName(name string) LevelLogger {
 	c := l.clone()
-	c.name = name
+	if c.name != "" {
+		c.name += "/" + name
+	} else {
+		c.name = name
+	}
+	return c
+}
+
+// WithContext returns a new logger that includes the values of the
+// context keys registered with RegisterContextKey found in the given
+// context in all log messages, e.g. "request_id=abc". They replace
+// the fields of an earlier call to WithContext, if there was one.
+func (l Logger) WithContext(ctx context.Context) LevelLogger {
+	c := l.clone()
+	c.fields = contextFields(ctx)
 	return c
 }
 
 // log logs a message at the given level.
 func (l Logger) log(lvl Level, v ...any) {
-	msg := fmt.Sprintln(v...)
+	l.output(lvl, fmt.Sprintln(v...))
+}
+
+// output writes the given message at the given level. Both log
+// and logf call it, so the caller is at the same call depth.
+func (l Logger) output(lvl Level, msg string) {
+	if l.json {
+		l.logJSON(lvl, msg)
+		return
+	}
 
+	for i := len(l.fields) - 1; i >= 0; i-- {
+		msg = l.fields[i].name + "=" + l.fields[i].value + " " + msg
+	}
 	if l.name != "" {
 		msg = "[" + lvl.String() + "] [" + l.name + "]: " + msg
 	} else {
 		msg = "[" + lvl.String() + "]: " + msg
 	}
 
-	if l.level == LevelDebug {
-		// Include call depth to show code
-		// line reference in verbose mode.
+	if l.fileInfo {
+		// Include call depth to show code line
+		// reference in verbose mode or if enabled.
 		_ = l.logger.Output(l.callDepth, msg)
 	} else {
 		// Directly log without call depth,
@@ -203,16 +334,55 @@ func (l Logger) log(lvl Level, v ...any) {
 	}
 }
 
+// jsonEntry is the JSON representation of a log message.
+type jsonEntry struct {
+	Level string `json:"level"`
+	Name  string `json:"name"`
+	Msg   string `json:"msg"`
+	TS    string `json:"ts"`
+
+	// Fields are the log fields from the context, if any.
+	Fields map[string]string `json:"fields,omitempty"`
+}
+
+// logJSON logs a message at the given level as a JSON object.
+func (l Logger) logJSON(lvl Level, msg string) {
+	entry := jsonEntry{
+		Level: strings.TrimSpace(lvl.String()),
+		Name:  l.name,
+		Msg:   strings.TrimSuffix(msg, "\n"),
+		TS:    time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if len(l.fields) > 0 {
+		entry.Fields = make(map[string]string, len(l.fields))
+		for _, f := range l.fields {
+			entry.Fields[f.name] = f.value
+		}
+	}
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		l.logger.Print("[" + errorLevel + "]: failed to marshal log message: " + err.Error())
+		return
+	}
+
+	l.logger.Print(string(b))
+}
+
 // logf logs a formatted message at the given level.
 func (l Logger) logf(lvl Level, format string, v ...any) {
-	l.log(lvl, fmt.Sprintf(format, v...))
+	l.output(lvl, fmt.Sprintf(format, v...)+"\n")
 }
 
 // clone returns a copy of the logger with the same settings.
 func (l Logger) clone() Logger {
 	return Logger{
 		logger:    l.logger,
+		name:      l.name,
 		level:     l.level,
 		callDepth: l.callDepth,
+		json:      l.json,
+		fileInfo:  l.fileInfo,
+		fields:    l.fields,
 	}
 }
@@ -2,8 +2,14 @@ package olog_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -62,6 +68,32 @@ func TestLogger_Infof(t *testing.T) {
 	a.Contains(buf.String(), expected)
 }
 
+func TestLogger_Warn(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelWarn, olog.WithWriter(&buf)).
+		WithName("TestLogger")
+
+	logger.Warn("warn message")
+
+	expected := fmt.Sprintf("[%s] [TestLogger]: warn message\n", olog.LevelWarn)
+	a.Contains(buf.String(), expected)
+}
+
+func TestLogger_Warnf(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelWarn, olog.WithWriter(&buf)).
+		WithName("TestLogger")
+
+	logger.Warnf("warn message %d", 1)
+
+	expected := fmt.Sprintf("[%s] [TestLogger]: warn message 1\n", olog.LevelWarn)
+	a.Contains(buf.String(), expected)
+}
+
 func TestLogger_Error(t *testing.T) {
 	a := assert.New(t)
 
@@ -87,3 +119,200 @@ func TestLogger_Errorf(t *testing.T) {
 	expected := fmt.Sprintf("[%s] [TestLogger]: error message 1\n", olog.LevelError)
 	a.Contains(buf.String(), expected)
 }
+
+func TestLogger_WithName(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelInfo, olog.WithWriter(&buf)).
+		WithName("a").
+		WithName("b")
+
+	logger.Info("info message")
+
+	expected := fmt.Sprintf("[%s] [a/b]: info message\n", olog.LevelInfo)
+	a.Contains(buf.String(), expected)
+}
+
+func TestLogger_WithNameKeepsLevel(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelError, olog.WithWriter(&buf)).
+		WithName("parent").
+		WithName("child")
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Warn("warn message")
+	logger.Error("error message")
+
+	expected := fmt.Sprintf("[%s] [parent/child]: error message\n", olog.LevelError)
+	a.Equal(1, strings.Count(buf.String(), "\n"))
+	a.Contains(buf.String(), expected)
+}
+
+// requestIDKey is the context key of the request ID in the tests.
+type requestIDKey struct{}
+
+func TestLogger_WithContext(t *testing.T) {
+	olog.RegisterContextKey(requestIDKey{}, "request_id")
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc123")
+
+	t.Run("Text", func(t *testing.T) {
+		a := assert.New(t)
+
+		var buf bytes.Buffer
+		logger := olog.NewLogger(olog.LevelInfo, olog.WithWriter(&buf)).
+			WithName("TestLogger").
+			WithContext(ctx)
+
+		logger.Infof("info message %d", 1)
+		logger.WithName("child").Warn("warn message")
+
+		a.Contains(buf.String(), fmt.Sprintf("[%s] [TestLogger]: request_id=abc123 info message 1\n", olog.LevelInfo))
+		a.Contains(buf.String(), fmt.Sprintf("[%s] [TestLogger/child]: request_id=abc123 warn message\n", olog.LevelWarn))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		a := assert.New(t)
+
+		var buf bytes.Buffer
+		logger := olog.NewLogger(olog.LevelInfo, olog.WithWriter(&buf), olog.WithJSON(true)).
+			WithContext(ctx)
+
+		logger.Info("info message")
+
+		var entry map[string]any
+		a.NoError(json.Unmarshal(buf.Bytes(), &entry))
+		a.Equal("info message", entry["msg"])
+		a.Equal(map[string]any{"request_id": "abc123"}, entry["fields"])
+	})
+
+	t.Run("NoValue", func(t *testing.T) {
+		a := assert.New(t)
+
+		var buf bytes.Buffer
+		logger := olog.NewLogger(olog.LevelInfo, olog.WithWriter(&buf)).
+			WithContext(context.Background())
+
+		logger.Info("info message")
+
+		a.Equal(fmt.Sprintf("[%s]: info message\n", olog.LevelInfo), buf.String())
+	})
+
+	t.Run("Noop", func(t *testing.T) {
+		a := assert.New(t)
+
+		noop := olog.NewNoopLogger()
+		a.Equal(noop, noop.WithContext(ctx))
+	})
+}
+
+func TestLogger_JSON(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelDebug, olog.WithWriter(&buf), olog.WithJSON(true)).
+		WithName("TestLogger")
+
+	logger.Debugf("debug message %d", 1)
+	logger.Error("error message")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	a.Len(lines, 2)
+
+	expected := []struct {
+		level string
+		msg   string
+	}{
+		{level: "debug", msg: "debug message 1"},
+		{level: "error", msg: "error message"},
+	}
+	for i, line := range lines {
+		var entry map[string]any
+		a.NoError(json.Unmarshal([]byte(line), &entry))
+
+		a.Equal(expected[i].level, entry["level"])
+		a.Equal("TestLogger", entry["name"])
+		a.Equal(expected[i].msg, entry["msg"])
+
+		ts, ok := entry["ts"].(string)
+		a.True(ok)
+		_, err := time.Parse(time.RFC3339Nano, ts)
+		a.NoError(err)
+	}
+}
+
+func TestLogger_FileInfo(t *testing.T) {
+	a := assert.New(t)
+
+	var buf bytes.Buffer
+	logger := olog.NewLogger(olog.LevelError, olog.WithWriter(&buf), olog.WithFileInfo(true)).
+		WithName("TestLogger")
+
+	logger.Error("error message")
+	logger.Errorf("error message %d", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	a.Len(lines, 2)
+	for _, line := range lines {
+		a.Regexp(`^olog_test\.go:\d+: \[error\] \[TestLogger\]: error message`, line)
+	}
+
+	buf.Reset()
+	logger = olog.NewLogger(olog.LevelError, olog.WithWriter(&buf), olog.WithFileInfo(false))
+	logger.Error("error message")
+	a.Equal(fmt.Sprintf("[%s]: error message\n", olog.LevelError), buf.String())
+}
+
+func TestLogger_Fatal(t *testing.T) {
+	// The fatal call exits the process, so it
+	// runs in a subprocess of the test binary.
+	if name := os.Getenv("OLOG_TEST_FATAL"); name != "" {
+		var lg olog.LevelLogger = olog.NewLogger(olog.LevelError).WithName("TestLogger")
+		if name == "Noop" {
+			lg = olog.NewNoopLogger()
+		}
+		switch name {
+		case "Fatal", "Noop":
+			lg.Fatal("fatal message")
+		case "Fatalf":
+			lg.Fatalf("fatal message %d", 1)
+		}
+		return
+	}
+
+	tests := map[string]struct {
+		expected string
+	}{
+		"Fatal": {
+			expected: fmt.Sprintf("[%s] [TestLogger]: fatal message\n", olog.LevelError),
+		},
+		"Fatalf": {
+			expected: fmt.Sprintf("[%s] [TestLogger]: fatal message 1\n", olog.LevelError),
+		},
+		"Noop": {
+			expected: "",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			a := assert.New(t)
+
+			c := exec.Command(os.Args[0], "-test.run=^TestLogger_Fatal$")
+			c.Env = append(os.Environ(), "OLOG_TEST_FATAL="+name)
+			var stderr bytes.Buffer
+			c.Stderr = &stderr
+
+			err := c.Run()
+			var exitErr *exec.ExitError
+			a.ErrorAs(err, &exitErr)
+			if exitErr != nil {
+				a.Equal(1, exitErr.ExitCode())
+			}
+			a.Equal(tc.expected, stderr.String())
+		})
+	}
+}
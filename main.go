@@ -2,8 +2,11 @@
 package main
 
 import (
+	"context"
 	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dannyhinshaw/converge/cmd"
 )
@@ -13,7 +16,15 @@ import (
 var version = "(dev)"
 
 func main() {
-	if err := cmd.NewRoot(version).Execute(); err != nil {
-		_, _ = io.WriteString(os.Stderr, err.Error())
+	// Cancel the converge operation when the user
+	// presses Ctrl-C or the process is terminated.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := cmd.NewRoot(version).ExecuteContext(ctx)
+	stop()
+
+	if err != nil {
+		_, _ = io.WriteString(os.Stderr, err.Error()+"\n")
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,67 @@
+package main_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain_ExitCode(t *testing.T) {
+	bin := buildBinary(t)
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "file1.go"), []byte("package main\nfunc func1() {}"), 0o644)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		args     []string
+		exitCode int
+	}{
+		"Success": {
+			args:     []string{"--dir", dir},
+			exitCode: 0,
+		},
+		"InvalidFlag": {
+			args:     []string{"--log-format", "xml"},
+			exitCode: 1,
+		},
+		"MissingDirectory": {
+			args:     []string{"--dir", filepath.Join(dir, "missing")},
+			exitCode: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := require.New(t)
+
+			out, err := exec.Command(bin, tc.args...).CombinedOutput()
+			if tc.exitCode == 0 {
+				r.NoError(err, string(out))
+				return
+			}
+
+			var exitErr *exec.ExitError
+			r.True(errors.As(err, &exitErr), "expected an exit error, got: %v", err)
+			r.Equal(tc.exitCode, exitErr.ExitCode())
+			r.NotEmpty(out)
+		})
+	}
+}
+
+// buildBinary builds the converge binary into a
+// temp directory and returns the path to it.
+func buildBinary(t *testing.T) string {
+	t.Helper()
+
+	bin := filepath.Join(t.TempDir(), "converge")
+	if out, err := exec.Command("go", "build", "-o", bin, ".").CombinedOutput(); err != nil {
+		t.Fatalf("Failed to build binary: %v\n%s", err, out)
+	}
+
+	return bin
+}
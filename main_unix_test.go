@@ -0,0 +1,46 @@
+//go:build unix
+
+package main_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain_Interrupt(t *testing.T) {
+	r := require.New(t)
+	bin := buildBinary(t)
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "file1.go"), []byte("package main\nfunc func1() {}"), 0o644)
+	r.NoError(err)
+
+	// The formatter blocks long enough for the
+	// interrupt to arrive mid-converge.
+	c := exec.Command(bin, "--dir", dir, "--formatter", "sleep 10")
+	r.NoError(c.Start())
+
+	time.Sleep(300 * time.Millisecond)
+	r.NoError(c.Process.Signal(os.Interrupt))
+	interrupted := time.Now()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	select {
+	case err = <-done:
+		var exitErr *exec.ExitError
+		r.True(errors.As(err, &exitErr), "expected an exit error, got: %v", err)
+		r.Equal(1, exitErr.ExitCode())
+		r.Less(time.Since(interrupted), 500*time.Millisecond, "converge took too long to exit after being interrupted")
+	case <-time.After(5 * time.Second):
+		_ = c.Process.Kill()
+		r.Fail("converge did not exit after being interrupted")
+	}
+}